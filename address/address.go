@@ -0,0 +1,177 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package address decides the address a server registers, so that it is the same for every registry.
+package address
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// interfaces returns the interfaces of the host, replaced in tests.
+var interfaces = func() ([]netInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]netInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, netInterface{name: iface.Name, flags: iface.Flags, addrs: addrs})
+	}
+	return res, nil
+}
+
+type netInterface struct {
+	name  string
+	flags net.Flags
+	addrs []net.Addr
+}
+
+// Advertise returns the address to register for a server listening on addr, in the form of host:port.
+//
+// The host is the first of:
+//   - env HERTZ_IP_TO_REGISTRY
+//   - the host of WithAdvertiseAddr
+//   - the host of addr, if it is specified
+//   - the host detected from the interfaces, see WithInterface, WithCIDR and WithPreferIPv6
+//
+// and the port is the first of env HERTZ_PORT_TO_REGISTRY, the port of WithAdvertiseAddr and the port of addr.
+func Advertise(addr net.Addr, opts ...Option) (string, error) {
+	host, port, err := AdvertiseHostPort(addr, opts...)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// AdvertiseHostPort is like Advertise, but returns the host and the port separately.
+func AdvertiseHostPort(addr net.Addr, opts ...Option) (host string, port int, err error) {
+	if addr == nil {
+		return "", 0, errors.New("address can not be empty")
+	}
+	o := newOptions(opts...)
+
+	host, portStr, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return "", 0, fmt.Errorf("parse address %s failed: %w", addr.String(), err)
+	}
+	if o.advertiseAddr != "" {
+		h, p, err := net.SplitHostPort(o.advertiseAddr)
+		if err != nil {
+			// no port in the advertise address
+			h, p = strings.Trim(o.advertiseAddr, "[]"), ""
+		}
+		if h != "" {
+			host = h
+		}
+		if p != "" {
+			portStr = p
+		}
+	}
+	if o.envHost != "" {
+		host = o.envHost
+	}
+	if o.envPort != "" {
+		portStr = o.envPort
+	}
+
+	port, err = strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q of address %s", portStr, addr.String())
+	}
+	if isUnspecified(host) {
+		if host, err = detectHost(o); err != nil {
+			return "", 0, err
+		}
+	}
+	return host, port, nil
+}
+
+func isUnspecified(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// detectHost returns the first address of the interfaces matching o, of the preferred family if any.
+func detectHost(o *options) (string, error) {
+	cidrs := make([]*net.IPNet, 0, len(o.cidrs))
+	for _, cidr := range o.cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return "", fmt.Errorf("parse cidr %s failed: %w", cidr, err)
+		}
+		cidrs = append(cidrs, ipNet)
+	}
+
+	ifaces, err := interfaces()
+	if err != nil {
+		return "", fmt.Errorf("list interfaces failed: %w", err)
+	}
+	var fallback net.IP
+	for _, iface := range ifaces {
+		if iface.flags&net.FlagUp == 0 {
+			continue
+		}
+		if o.iface != "" && iface.name != o.iface {
+			continue
+		}
+		// the loopback interface is only used if it is named explicitly
+		if o.iface == "" && iface.flags&net.FlagLoopback != 0 {
+			continue
+		}
+		for _, addr := range iface.addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !usable(ipNet.IP, o.iface != "", cidrs) {
+				continue
+			}
+			if (ipNet.IP.To4() == nil) == o.preferIPv6 {
+				return ipNet.IP.String(), nil
+			}
+			if fallback == nil {
+				fallback = ipNet.IP
+			}
+		}
+	}
+	if fallback != nil {
+		return fallback.String(), nil
+	}
+	return "", fmt.Errorf("no address found in interface %q and cidr %v", o.iface, o.cidrs)
+}
+
+func usable(ip net.IP, allowLoopback bool, cidrs []*net.IPNet) bool {
+	if ip.IsUnspecified() || (ip.IsLoopback() && !allowLoopback) {
+		return false
+	}
+	if len(cidrs) == 0 {
+		// a link-local address is only used if it is selected by a cidr
+		return !ip.IsLinkLocalUnicast()
+	}
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package address
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustIPNet(t *testing.T, cidr string) *net.IPNet {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	require.Nil(t, err)
+	ipNet.IP = ip
+	return ipNet
+}
+
+func setupInterfaces(t *testing.T) {
+	prev := interfaces
+	interfaces = func() ([]netInterface, error) {
+		return []netInterface{
+			{name: "lo", flags: net.FlagUp | net.FlagLoopback, addrs: []net.Addr{mustIPNet(t, "127.0.0.1/8"), mustIPNet(t, "::1/128")}},
+			{name: "down0", flags: 0, addrs: []net.Addr{mustIPNet(t, "10.0.0.9/24")}},
+			{name: "eth0", flags: net.FlagUp, addrs: []net.Addr{mustIPNet(t, "fe80::1/64"), mustIPNet(t, "2001:db8::1/64"), mustIPNet(t, "10.0.0.1/24")}},
+			{name: "eth1", flags: net.FlagUp, addrs: []net.Addr{mustIPNet(t, "192.168.1.1/24"), mustIPNet(t, "2001:db8:1::1/64")}},
+		}, nil
+	}
+	t.Cleanup(func() {
+		interfaces = prev
+	})
+}
+
+func setupEnv(t *testing.T, key, value string) {
+	prev, ok := os.LookupEnv(key)
+	require.Nil(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, prev)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+func TestAdvertise(t *testing.T) {
+	setupInterfaces(t)
+	cases := []struct {
+		name string
+		addr string
+		opts []Option
+		want string
+	}{
+		{"specified", "127.0.0.1:8888", nil, "127.0.0.1:8888"},
+		{"empty host", ":8888", nil, "10.0.0.1:8888"},
+		{"ipv4 wildcard", "0.0.0.0:8888", nil, "10.0.0.1:8888"},
+		{"ipv6 wildcard", "[::]:8888", nil, "10.0.0.1:8888"},
+		{"prefer ipv6", "[::]:8888", []Option{WithPreferIPv6(true)}, "[2001:db8::1]:8888"},
+		{"interface", "[::]:8888", []Option{WithInterface("eth1")}, "192.168.1.1:8888"},
+		{"interface ipv6", "[::]:8888", []Option{WithInterface("eth1"), WithPreferIPv6(true)}, "[2001:db8:1::1]:8888"},
+		{"loopback interface", "[::]:8888", []Option{WithInterface("lo")}, "127.0.0.1:8888"},
+		{"cidr", "[::]:8888", []Option{WithCIDR("172.16.0.0/12", "192.168.0.0/16")}, "192.168.1.1:8888"},
+		{"cidr other family", "[::]:8888", []Option{WithCIDR("2001:db8:1::/48")}, "[2001:db8:1::1]:8888"},
+		{"cidr link-local", "[::]:8888", []Option{WithCIDR("fe80::/10")}, "[fe80::1]:8888"},
+		{"advertise host", "[::]:8888", []Option{WithAdvertiseAddr("demo.local")}, "demo.local:8888"},
+		{"advertise host and port", "[::]:8888", []Option{WithAdvertiseAddr("10.1.1.1:9999")}, "10.1.1.1:9999"},
+		{"advertise ipv6 host", "[::]:8888", []Option{WithAdvertiseAddr("2001:db8::2")}, "[2001:db8::2]:8888"},
+		{"advertise port", "[::]:8888", []Option{WithAdvertiseAddr(":9999")}, "10.0.0.1:9999"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tcpAddr, err := net.ResolveTCPAddr("tcp", c.addr)
+			require.Nil(t, err)
+			addr, err := Advertise(tcpAddr, c.opts...)
+			require.Nil(t, err)
+			assert.Equal(t, c.want, addr)
+		})
+	}
+}
+
+func TestAdvertiseEnv(t *testing.T) {
+	setupInterfaces(t)
+	tcpAddr, err := net.ResolveTCPAddr("tcp", "[::]:8888")
+	require.Nil(t, err)
+
+	setupEnv(t, EnvInterface, "eth1")
+	setupEnv(t, EnvIPFamily, "ipv6")
+	addr, err := Advertise(tcpAddr)
+	require.Nil(t, err)
+	assert.Equal(t, "[2001:db8:1::1]:8888", addr)
+	// the options take precedence over the env
+	addr, err = Advertise(tcpAddr, WithInterface("eth0"), WithPreferIPv6(false))
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8888", addr)
+
+	setupEnv(t, EnvCIDR, "192.168.0.0/16, 10.0.0.0/8")
+	setupEnv(t, EnvInterface, "")
+	addr, err = Advertise(tcpAddr)
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8888", addr)
+
+	// the ip and port to registry override any address
+	setupEnv(t, EnvIPToRegistry, "127.0.0.2")
+	setupEnv(t, EnvPortToRegistry, "8899")
+	host, port, err := AdvertiseHostPort(tcpAddr, WithAdvertiseAddr("10.1.1.1:9999"))
+	require.Nil(t, err)
+	assert.Equal(t, "127.0.0.2", host)
+	assert.Equal(t, 8899, port)
+}
+
+func TestAdvertiseError(t *testing.T) {
+	setupInterfaces(t)
+	_, err := Advertise(nil)
+	assert.NotNil(t, err)
+	_, err = Advertise(&net.UnixAddr{Name: "/tmp/hertz.sock", Net: "unix"})
+	assert.NotNil(t, err)
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", "[::]:0")
+	require.Nil(t, err)
+	_, err = Advertise(tcpAddr)
+	assert.NotNil(t, err)
+
+	tcpAddr, err = net.ResolveTCPAddr("tcp", "[::]:8888")
+	require.Nil(t, err)
+	_, err = Advertise(tcpAddr, WithInterface("eth9"))
+	assert.NotNil(t, err)
+	_, err = Advertise(tcpAddr, WithCIDR("172.16.0.0/12"))
+	assert.NotNil(t, err)
+	_, err = Advertise(tcpAddr, WithCIDR("invalid"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,5 @@
+module github.com/hertz-contrib/registry/address
+
+go 1.16
+
+require github.com/stretchr/testify v1.10.0
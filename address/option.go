@@ -0,0 +1,104 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package address
+
+import (
+	"os"
+	"strings"
+)
+
+// The environment variables read by Advertise, the options take precedence over
+// them except EnvIPToRegistry and EnvPortToRegistry, which override any address.
+const (
+	// EnvIPToRegistry is the host to register.
+	EnvIPToRegistry = "HERTZ_IP_TO_REGISTRY"
+	// EnvPortToRegistry is the port to register.
+	EnvPortToRegistry = "HERTZ_PORT_TO_REGISTRY"
+	// EnvInterface is the name of the interface to detect the host from, see WithInterface.
+	EnvInterface = "HERTZ_REGISTRY_INTERFACE"
+	// EnvCIDR is the comma separated CIDRs which the detected host must belong to, see WithCIDR.
+	EnvCIDR = "HERTZ_REGISTRY_CIDR"
+	// EnvIPFamily is the preferred family of the detected host, "ipv4" or "ipv6", see WithPreferIPv6.
+	EnvIPFamily = "HERTZ_REGISTRY_IP_FAMILY"
+)
+
+type options struct {
+	advertiseAddr string
+	iface         string
+	cidrs         []string
+	preferIPv6    bool
+
+	envHost string
+	envPort string
+}
+
+// Option is the option of the address to register.
+type Option func(o *options)
+
+// WithAdvertiseAddr sets the address to register instead of the listening address of the server,
+// in the form of host:port, or host to keep the listening port.
+func WithAdvertiseAddr(addr string) Option {
+	return func(o *options) {
+		o.advertiseAddr = addr
+	}
+}
+
+// WithInterface detects the host from the interface named name only, e.g. "eth0".
+// Default: all the interfaces which are up, except the loopback ones
+func WithInterface(name string) Option {
+	return func(o *options) {
+		o.iface = name
+	}
+}
+
+// WithCIDR detects a host belonging to one of cidrs only, e.g. "10.0.0.0/8".
+func WithCIDR(cidrs ...string) Option {
+	return func(o *options) {
+		o.cidrs = cidrs
+	}
+}
+
+// WithPreferIPv6 detects an IPv6 host first if prefer is true, otherwise an IPv4 host first.
+// The other family is used if no host of the preferred family is found.
+// Default: false
+func WithPreferIPv6(prefer bool) Option {
+	return func(o *options) {
+		o.preferIPv6 = prefer
+	}
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		iface:      os.Getenv(EnvInterface),
+		preferIPv6: strings.EqualFold(os.Getenv(EnvIPFamily), "ipv6"),
+		envHost:    os.Getenv(EnvIPToRegistry),
+		envPort:    os.Getenv(EnvPortToRegistry),
+	}
+	if cidrs := os.Getenv(EnvCIDR); cidrs != "" {
+		for _, cidr := range strings.Split(cidrs, ",") {
+			if cidr = strings.TrimSpace(cidr); cidr != "" {
+				o.cidrs = append(o.cidrs, cidr)
+			}
+		}
+	}
+	o.apply(opts...)
+	return o
+}
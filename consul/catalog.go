@@ -0,0 +1,252 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	DefaultCatalogHeartbeatInterval = 10 * time.Second
+
+	catalogCheckName = "Hertz heartbeat"
+	// catalogCheckOutput is the output of the passing check, constant so that a heartbeat does not
+	// modify the check when the health is unchanged
+	catalogCheckOutput = "Hertz heartbeat passing"
+)
+
+// catalogEntry is a service registered in the catalog, which is kept alive by the heartbeat of the registry.
+type catalogEntry struct {
+	reg *api.CatalogRegistration
+	// dedicatedNode is set if the node is registered for the service only, and is deregistered with it
+	dedicatedNode bool
+	draining      bool
+	cancel        context.CancelFunc
+	// done is closed when the heartbeat of the entry has stopped
+	done chan struct{}
+}
+
+// WithCatalogMode is consul registry option to register the services in the catalog of the consul servers
+// directly, instead of the local agent, which works without a consul agent.
+// The services are registered on the external node named node, or on a node per service if node is empty.
+// The registry keeps the services alive by a heartbeat, see WithCatalogHeartbeat.
+func WithCatalogMode(node string) Option {
+	return func(o *options) {
+		o.catalog = true
+		o.catalogNode = node
+	}
+}
+
+// WithCatalogHeartbeat is consul registry option to set the interval of the heartbeat in catalog mode,
+// which registers the services again and updates their health. Default: 10s
+func WithCatalogHeartbeat(interval time.Duration) Option {
+	return func(o *options) { o.catalogHeartbeat = interval }
+}
+
+// registerCatalog registers svcInfo in the catalog and starts its heartbeat, replacing the previous one.
+func (c *consulRegistry) registerCatalog(svcInfo *api.AgentServiceRegistration) error {
+	node, dedicated := c.opts.catalogNode, false
+	if node == "" {
+		node, dedicated = catalogNodeName(svcInfo.ID), true
+	}
+	var connect *api.AgentServiceConnect
+	if svcInfo.Connect != nil && svcInfo.Connect.Native {
+		// the sidecar proxies can only be registered by an agent
+		connect = &api.AgentServiceConnect{Native: true}
+	}
+	entry := &catalogEntry{
+		reg: &api.CatalogRegistration{
+			Node:    node,
+			Address: svcInfo.Address,
+			// the node is not managed by an agent
+			NodeMeta:       map[string]string{"external-node": "true"},
+			SkipNodeUpdate: !dedicated,
+			Partition:      c.opts.partition,
+			Service: &api.AgentService{
+				ID:        svcInfo.ID,
+				Service:   svcInfo.Name,
+				Tags:      svcInfo.Tags,
+				Meta:      svcInfo.Meta,
+				Port:      svcInfo.Port,
+				Address:   svcInfo.Address,
+				Weights:   *svcInfo.Weights,
+				Connect:   connect,
+				Namespace: c.opts.namespace,
+				Partition: c.opts.partition,
+			},
+			Check: &api.AgentCheck{
+				Node:        node,
+				CheckID:     "service:" + svcInfo.ID + ":heartbeat",
+				Name:        catalogCheckName,
+				ServiceID:   svcInfo.ID,
+				ServiceName: svcInfo.Name,
+				Namespace:   c.opts.namespace,
+				Partition:   c.opts.partition,
+			},
+		},
+		dedicatedNode: dedicated,
+		done:          make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry.cancel = cancel
+	if err := c.heartbeat(ctx, entry); err != nil {
+		cancel()
+		return err
+	}
+
+	c.mu.Lock()
+	prev, ok := c.catalogEntries[svcInfo.ID]
+	c.catalogEntries[svcInfo.ID] = entry
+	c.mu.Unlock()
+	if ok {
+		prev.stop()
+	}
+
+	go c.keepHeartbeat(ctx, entry)
+	return nil
+}
+
+// deregisterCatalog stops the heartbeat of the service svcID and deregisters it from the catalog.
+func (c *consulRegistry) deregisterCatalog(svcID string) error {
+	c.mu.Lock()
+	entry, ok := c.catalogEntries[svcID]
+	delete(c.catalogEntries, svcID)
+	c.mu.Unlock()
+
+	dereg := &api.CatalogDeregistration{
+		Node:      c.opts.catalogNode,
+		ServiceID: svcID,
+		Namespace: c.opts.namespace,
+		Partition: c.opts.partition,
+	}
+	if ok {
+		// a heartbeat in flight would register the service again after it is deregistered
+		entry.stop()
+		dereg.Node = entry.reg.Node
+		if entry.dedicatedNode {
+			// deregistering the node removes the service and the check as well
+			dereg.ServiceID = ""
+		}
+	} else if dereg.Node == "" {
+		dereg.Node = catalogNodeName(svcID)
+		dereg.ServiceID = ""
+	}
+	_, err := c.consulClient.Catalog().Deregister(dereg, c.writeOptions(context.Background()))
+	return err
+}
+
+// drainCatalog marks the service svcID as critical, so that it is no longer resolved while it is still registered.
+func (c *consulRegistry) drainCatalog(svcID string) error {
+	c.mu.Lock()
+	entry, ok := c.catalogEntries[svcID]
+	if ok {
+		entry.draining = true
+	}
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("service %s has not registered", svcID)
+	}
+	return c.heartbeat(context.Background(), entry)
+}
+
+func (c *consulRegistry) keepHeartbeat(ctx context.Context, entry *catalogEntry) {
+	defer close(entry.done)
+	interval := c.opts.catalogHeartbeat
+	if interval <= 0 {
+		interval = DefaultCatalogHeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.heartbeat(ctx, entry); err != nil && ctx.Err() == nil {
+				hlog.Warnf("HERTZ: heartbeat of service %s failed with err: %v", entry.reg.Service.ID, err)
+			}
+		}
+	}
+}
+
+// heartbeat registers the entry again with the current health of the service,
+// which is critical if it is draining or any health function of WithTTLCheck fails.
+func (c *consulRegistry) heartbeat(ctx context.Context, entry *catalogEntry) error {
+	status, output := api.HealthPassing, catalogCheckOutput
+	c.mu.Lock()
+	draining := entry.draining
+	c.mu.Unlock()
+	if draining {
+		status, output = api.HealthCritical, drainReason
+	} else if err := c.health(ctx); err != nil {
+		status, output = api.HealthCritical, err.Error()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	reg := *entry.reg
+	check := *reg.Check
+	check.Status, check.Output = status, output
+	reg.Check = &check
+	_, err := c.consulClient.Catalog().Register(&reg, c.writeOptions(ctx))
+	return err
+}
+
+// health returns the first error of the health functions of WithTTLCheck.
+func (c *consulRegistry) health(ctx context.Context) error {
+	for _, sc := range c.opts.checks {
+		if sc.health == nil {
+			continue
+		}
+		if err := sc.health(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *consulRegistry) writeOptions(ctx context.Context) *api.WriteOptions {
+	wo := &api.WriteOptions{
+		Token:     c.opts.token,
+		Namespace: c.opts.namespace,
+		Partition: c.opts.partition,
+	}
+	return wo.WithContext(ctx)
+}
+
+// stop stops the heartbeat of the entry and waits for it to return.
+func (e *catalogEntry) stop() {
+	e.cancel()
+	<-e.done
+}
+
+// catalogNodeName returns the name of the node dedicated to the service svcID,
+// with the characters invalid in a DNS label replaced by '-'.
+func catalogNodeName(svcID string) string {
+	return "hertz-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, svcID)
+}
@@ -0,0 +1,191 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hashicorp/consul/api"
+)
+
+// HealthFunc reports the health of the service for a TTL check, nil means passing.
+// ctx is canceled when the service is deregistered.
+type HealthFunc func(ctx context.Context) error
+
+// serviceCheck builds a check of the registered service.
+type serviceCheck struct {
+	// build returns the check of the service listening on addr, host:port
+	build func(addr string) *api.AgentServiceCheck
+	// ttl and health are set for a TTL check only
+	ttl    time.Duration
+	health HealthFunc
+}
+
+// WithHTTPCheck adds an HTTP check requesting path on the registered address, e.g. /health.
+// Interval, Timeout, Method, Header and the other fields of check are kept, a nil check uses the default config.
+func WithHTTPCheck(path string, check *api.AgentServiceCheck) Option {
+	return func(o *options) {
+		o.checks = append(o.checks, serviceCheck{
+			build: func(addr string) *api.AgentServiceCheck {
+				c := copyCheck(check)
+				c.HTTP = "http://" + addr + "/" + strings.TrimPrefix(path, "/")
+				return c
+			},
+		})
+	}
+}
+
+// WithGRPCCheck adds a gRPC check calling the standard gRPC health checking protocol on the registered address.
+// service is the service to check, or empty to check the whole server.
+// Interval, Timeout, GRPCUseTLS and the other fields of check are kept, a nil check uses the default config.
+func WithGRPCCheck(service string, check *api.AgentServiceCheck) Option {
+	return func(o *options) {
+		o.checks = append(o.checks, serviceCheck{
+			build: func(addr string) *api.AgentServiceCheck {
+				c := copyCheck(check)
+				c.GRPC = addr
+				if service != "" {
+					c.GRPC += "/" + service
+				}
+				return c
+			},
+		})
+	}
+}
+
+// WithTTLCheck adds a TTL check, which is updated by the registry every ttl/2 with the result of health.
+// A nil health is always passing, so that the check only tells whether the registry is alive.
+func WithTTLCheck(ttl time.Duration, health HealthFunc) Option {
+	if health == nil {
+		health = func(context.Context) error { return nil }
+	}
+	return func(o *options) {
+		o.checks = append(o.checks, serviceCheck{
+			build: func(string) *api.AgentServiceCheck {
+				return &api.AgentServiceCheck{
+					TTL:                            ttl.String(),
+					DeregisterCriticalServiceAfter: DefaultCheckDeregisterCriticalServiceAfter,
+				}
+			},
+			ttl:    ttl,
+			health: health,
+		})
+	}
+}
+
+// copyCheck returns a copy of check, so that the check of an option is not changed by a registration.
+func copyCheck(check *api.AgentServiceCheck) *api.AgentServiceCheck {
+	if check == nil {
+		return defaultCheck()
+	}
+	c := *check
+	return &c
+}
+
+// buildChecks returns the checks of the service svcID listening on host:port.
+func (c *consulRegistry) buildChecks(svcID, host string, port int) api.AgentServiceChecks {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	var checks api.AgentServiceChecks
+	if c.opts.check != nil {
+		check := copyCheck(c.opts.check)
+		check.TCP = addr
+		checks = append(checks, check)
+	}
+	for i, sc := range c.opts.checks {
+		check := sc.build(addr)
+		if sc.health != nil {
+			check.CheckID = ttlCheckID(svcID, i)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func ttlCheckID(svcID string, i int) string {
+	return fmt.Sprintf("service:%s:ttl:%d", svcID, i)
+}
+
+// startTTLChecks starts updating the TTL checks of the service svcID, replacing the previous updaters of it.
+func (c *consulRegistry) startTTLChecks(svcID string) {
+	c.stopTTLChecks(svcID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := false
+	for i, sc := range c.opts.checks {
+		if sc.health == nil {
+			continue
+		}
+		started = true
+		checkID := ttlCheckID(svcID, i)
+		// update once before returning, so that the service is passing as soon as it is registered
+		c.updateTTL(ctx, checkID, sc.health)
+		go c.keepTTL(ctx, checkID, sc)
+	}
+	if !started {
+		cancel()
+		return
+	}
+
+	c.mu.Lock()
+	c.ttlCancels[svcID] = cancel
+	c.mu.Unlock()
+}
+
+// stopTTLChecks stops updating the TTL checks of the service svcID.
+func (c *consulRegistry) stopTTLChecks(svcID string) {
+	c.mu.Lock()
+	cancel, ok := c.ttlCancels[svcID]
+	delete(c.ttlCancels, svcID)
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (c *consulRegistry) keepTTL(ctx context.Context, checkID string, sc serviceCheck) {
+	interval := sc.ttl / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.updateTTL(ctx, checkID, sc.health)
+		}
+	}
+}
+
+func (c *consulRegistry) updateTTL(ctx context.Context, checkID string, health HealthFunc) {
+	status, output := api.HealthPassing, ""
+	if err := health(ctx); err != nil {
+		status, output = api.HealthCritical, err.Error()
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err := c.consulClient.Agent().UpdateTTLOpts(checkID, output, status, c.queryOptions()); err != nil {
+		hlog.Warnf("HERTZ: update ttl check %s failed with err: %v", checkID, err)
+	}
+}
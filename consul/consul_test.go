@@ -16,9 +16,12 @@ package consul
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -126,6 +129,27 @@ func TestNewConsulRegisterWithCheckOption(t *testing.T) {
 	assert.NotNil(t, consulResolver)
 }
 
+// TestNewConsulFromConfig tests the constructors building the client from the config.
+func TestNewConsulFromConfig(t *testing.T) {
+	config := consulapi.DefaultConfig()
+	config.Address = consulAddr
+	config.Token = "token"
+
+	r, err := NewConsulRegisterFromConfig(config, WithToken("registry-token"), WithNamespace("ns"), WithPartition("part"))
+	assert.Nil(t, err)
+	assert.Equal(t, &consulapi.QueryOptions{Token: "registry-token", Namespace: "ns", Partition: "part"}, r.(*consulRegistry).queryOptions())
+
+	res, err := NewConsulResolverFromConfig(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+
+	config.TLSConfig.CAFile = "/not/exist/ca.pem"
+	_, err = NewConsulRegisterFromConfig(config)
+	assert.NotNil(t, err)
+	_, err = NewConsulResolverFromConfig(config)
+	assert.NotNil(t, err)
+}
+
 // TestNewConsulResolver tests the NewConsulResolver function .
 func TestNewConsulResolver(t *testing.T) {
 	t.Parallel()
@@ -188,6 +212,71 @@ func TestConsulRegister(t *testing.T) {
 	}
 }
 
+// TestConsulRegisterWithChecks tests the HTTP and TTL checks.
+func TestConsulRegisterWithChecks(t *testing.T) {
+	t.Parallel()
+	config := consulapi.DefaultConfig()
+	config.Address = consulAddr
+	consulClient, err := consulapi.NewClient(config)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	var (
+		testSvcName = "hertz.test.checks"
+		testSvcPort = fmt.Sprintf("%d", 8584)
+		testSvcAddr = net.JoinHostPort(localIpAddr, testSvcPort)
+		healthy     = int32(1)
+	)
+
+	check := new(consulapi.AgentServiceCheck)
+	check.Timeout = "1s"
+	check.Interval = "1s"
+	r := NewConsulRegister(consulClient,
+		WithHTTPCheck("/health", check),
+		WithTTLCheck(2*time.Second, func(ctx context.Context) error {
+			if atomic.LoadInt32(&healthy) == 0 {
+				return errors.New("unhealthy")
+			}
+			return nil
+		}),
+	)
+	info := &registry.Info{
+		ServiceName: testSvcName,
+		Addr:        utils.NewNetAddr("tcp", testSvcAddr),
+		Weight:      10,
+	}
+	h := server.Default(server.WithHostPorts(testSvcAddr))
+	h.GET("/health", func(c context.Context, ctx *app.RequestContext) {
+		ctx.JSON(consts.StatusOK, utils.H{"status": "ok"})
+	})
+	go h.Spin()
+	time.Sleep(time.Second)
+	assert.Nil(t, r.Register(info))
+	defer r.Deregister(info)
+
+	checks, _, err := consulClient.Health().Checks(testSvcName, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, checks, 2) {
+		for _, c := range checks {
+			// no tcp check is registered with other checks
+			assert.Contains(t, []string{"http", "ttl"}, c.Type)
+		}
+	}
+	assert.Eventually(t, func() bool {
+		list, _, err := consulClient.Health().Service(testSvcName, "", true, nil)
+		return err == nil && len(list) == 1
+	}, 10*time.Second, 200*time.Millisecond)
+
+	// the ttl check turns critical with the health function
+	atomic.StoreInt32(&healthy, 0)
+	assert.Eventually(t, func() bool {
+		list, _, err := consulClient.Health().Service(testSvcName, "", true, nil)
+		return err == nil && len(list) == 0
+	}, 10*time.Second, 200*time.Millisecond)
+}
+
 // TestConsulDiscovery tests the ConsulDiscovery function with Hertz.
 func TestConsulDiscovery(t *testing.T) {
 	t.Parallel()
@@ -307,3 +396,336 @@ func TestConsulDeregister(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Len(t, result.Instances, 0)
 }
+
+// TestConsulBlockingResolver tests the resolver in blocking query mode.
+func TestConsulBlockingResolver(t *testing.T) {
+	t.Parallel()
+	consulConfig := consulapi.DefaultConfig()
+	consulConfig.Address = consulAddr
+	consulClient, err := consulapi.NewClient(consulConfig)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	var (
+		testSvcName  = "hertz.test.demo4"
+		testSvcAddr  = net.JoinHostPort(localIpAddr, "8584")
+		ctx          = context.Background()
+		changed      = make(chan discovery.Result, 10)
+		registryInfo = &registry.Info{
+			ServiceName: testSvcName,
+			Addr:        utils.NewNetAddr("tcp", testSvcAddr),
+			Weight:      777,
+		}
+	)
+
+	r := NewConsulRegister(consulClient)
+	res := NewConsulResolver(consulClient, WithBlockingQuery(time.Second*10),
+		WithChangeNotify(func(desc string, result discovery.Result) {
+			changed <- result
+		}))
+
+	// the first resolve starts the blocking query
+	result, err := res.Resolve(ctx, testSvcName)
+	assert.Nil(t, err)
+	assert.Len(t, result.Instances, 0)
+
+	h := server.Default(
+		server.WithHostPorts(testSvcAddr),
+		server.WithRegistry(r, registryInfo),
+	)
+	h.GET("/ping", func(c context.Context, ctx *app.RequestContext) {
+		ctx.JSON(consts.StatusOK, utils.H{"ping": "pong1"})
+	})
+	go h.Spin()
+
+	// wait for health check passing
+	select {
+	case result = <-changed:
+		assert.Len(t, result.Instances, 1)
+	case <-time.After(time.Second * 15):
+		t.Fatal("register is not notified")
+	}
+	result, err = res.Resolve(ctx, testSvcName)
+	assert.Nil(t, err)
+	assert.Len(t, result.Instances, 1)
+
+	err = h.Shutdown(ctx)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	select {
+	case result = <-changed:
+		assert.Len(t, result.Instances, 0)
+	case <-time.After(time.Second * 15):
+		t.Fatal("deregister is not notified")
+	}
+
+	// closing the resolver stops the blocking queries
+	assert.Nil(t, res.(io.Closer).Close())
+	_, err = res.Resolve(ctx, testSvcName)
+	assert.Equal(t, ErrResolverClosed, err)
+}
+
+func TestNextIndex(t *testing.T) {
+	assert.Equal(t, uint64(1), nextIndex(0, 0))
+	assert.Equal(t, uint64(10), nextIndex(0, 10))
+	assert.Equal(t, uint64(12), nextIndex(10, 12))
+	// index goes backwards
+	assert.Equal(t, uint64(0), nextIndex(10, 5))
+}
+
+func TestTargetDescription(t *testing.T) {
+	desc := cResolver.Target(context.Background(), &discovery.TargetInfo{Host: "hertz.test.demo"})
+	assert.Equal(t, "hertz.test.demo", desc)
+	sq, err := parseDesc(desc)
+	assert.Nil(t, err)
+	assert.Equal(t, serviceQuery{service: "hertz.test.demo"}, sq)
+
+	desc = cResolver.Target(context.Background(), &discovery.TargetInfo{
+		Host: "hertz.test.demo",
+		Tags: map[string]string{
+			"env":         "prod",
+			"canary":      "",
+			TagDatacenter: "dc2",
+			TagNamespace:  "ns",
+			TagPartition:  "part",
+			TagFilter:     `Service.Meta.version == "v2"`,
+		},
+	})
+	sq, err = parseDesc(desc)
+	assert.Nil(t, err)
+	assert.Equal(t, serviceQuery{
+		service:    "hertz.test.demo",
+		tags:       map[string]string{"canary": "", "env": "prod"},
+		datacenter: "dc2",
+		namespace:  "ns",
+		partition:  "part",
+		filter:     `Service.Meta.version == "v2"`,
+	}, sq)
+
+	// the same tags always have the same description
+	assert.Equal(t, desc, cResolver.Target(context.Background(), &discovery.TargetInfo{
+		Host: "hertz.test.demo",
+		Tags: map[string]string{
+			TagFilter:     `Service.Meta.version == "v2"`,
+			TagPartition:  "part",
+			TagNamespace:  "ns",
+			TagDatacenter: "dc2",
+			"canary":      "",
+			"env":         "prod",
+		},
+	}))
+}
+
+// TestConsulResolveWithTags tests resolving the instances having the tags of the target.
+func TestConsulResolveWithTags(t *testing.T) {
+	t.Parallel()
+	config := consulapi.DefaultConfig()
+	config.Address = consulAddr
+	consulClient, err := consulapi.NewClient(config)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	testSvcName := "hertz.test.tags"
+	r := NewConsulRegister(consulClient, WithCheck(nil))
+	infos := []*registry.Info{
+		{
+			ServiceName: testSvcName,
+			Addr:        utils.NewNetAddr("tcp", net.JoinHostPort(localIpAddr, "8585")),
+			Weight:      10,
+			Tags:        map[string]string{"env": "prod"},
+		},
+		{
+			ServiceName: testSvcName,
+			Addr:        utils.NewNetAddr("tcp", net.JoinHostPort(localIpAddr, "8586")),
+			Weight:      10,
+			Tags:        map[string]string{"env": "test"},
+		},
+	}
+	for _, info := range infos {
+		assert.Nil(t, r.Register(info))
+		defer r.Deregister(info)
+	}
+
+	desc := cResolver.Target(context.Background(), &discovery.TargetInfo{Host: testSvcName})
+	result, err := cResolver.Resolve(context.Background(), desc)
+	assert.Nil(t, err)
+	assert.Len(t, result.Instances, 2)
+
+	desc = cResolver.Target(context.Background(), &discovery.TargetInfo{Host: testSvcName, Tags: map[string]string{"env": "prod"}})
+	result, err = cResolver.Resolve(context.Background(), desc)
+	assert.Nil(t, err)
+	if assert.Len(t, result.Instances, 1) {
+		assert.Equal(t, net.JoinHostPort(localIpAddr, "8585"), result.Instances[0].Address().String())
+	}
+
+	desc = cResolver.Target(context.Background(), &discovery.TargetInfo{Host: testSvcName, Tags: map[string]string{TagFilter: "Service.Port == 8586"}})
+	result, err = cResolver.Resolve(context.Background(), desc)
+	assert.Nil(t, err)
+	if assert.Len(t, result.Instances, 1) {
+		assert.Equal(t, net.JoinHostPort(localIpAddr, "8586"), result.Instances[0].Address().String())
+	}
+}
+
+func TestTagFormat(t *testing.T) {
+	tagMap := map[string]string{
+		"version":  "v1:2",
+		"endpoint": "http://127.0.0.1:8080/ping",
+		"canary":   "",
+	}
+	for _, format := range []TagFormat{TagFormatTags, TagFormatMeta, TagFormatCompatible} {
+		tags, meta, err := convTagMap(tagMap, format)
+		assert.Nil(t, err)
+		assert.Equal(t, tagMap, splitTagsAndMeta(tags, meta, format))
+	}
+
+	tags, meta, err := convTagMap(tagMap, TagFormatMeta)
+	assert.Nil(t, err)
+	assert.Empty(t, tags)
+	assert.Equal(t, tagMap, meta)
+
+	// keys invalid in the Meta are stored in the tags only
+	tags, meta, err = convTagMap(map[string]string{"a.b": "1", "env": "prod"}, TagFormatCompatible)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a.b:1", "env:prod"}, tags)
+	assert.Equal(t, map[string]string{"env": "prod"}, meta)
+	_, _, err = convTagMap(map[string]string{"a:b": "1"}, TagFormatTags)
+	assert.ErrorIs(t, err, errIllegalTagChar)
+	_, _, err = convTagMap(map[string]string{"a.b": "1"}, TagFormatMeta)
+	assert.ErrorIs(t, err, errIllegalMetaKey)
+	_, _, err = convTagMap(map[string]string{"a:b": "1"}, TagFormatCompatible)
+	assert.ErrorIs(t, err, errIllegalTagChar)
+
+	// the Meta takes precedence when both formats are read
+	assert.Equal(t, map[string]string{"version": "v1:2", "env": "prod"},
+		splitTagsAndMeta([]string{"version:v1", "env:prod"}, map[string]string{"version": "v1:2"}, TagFormatCompatible))
+}
+
+func TestBuildConnect(t *testing.T) {
+	weights := &consulapi.AgentWeights{Passing: 10, Warning: 10}
+	assert.Nil(t, buildConnect(nil, "10.0.0.1", 8888, weights))
+
+	native := &consulapi.AgentServiceConnect{Native: true}
+	assert.Equal(t, native, buildConnect(native, "10.0.0.1", 8888, weights))
+
+	upstream := consulapi.Upstream{DestinationName: "hertz.test.upstream", LocalBindPort: 9191}
+	var o options
+	WithSidecarProxy(upstream)(&o)
+	connect := buildConnect(o.connect, "10.0.0.1", 8888, weights)
+	if assert.NotNil(t, connect.SidecarService) {
+		assert.Equal(t, "10.0.0.1", connect.SidecarService.Address)
+		assert.Equal(t, weights, connect.SidecarService.Weights)
+		assert.Equal(t, "10.0.0.1", connect.SidecarService.Proxy.LocalServiceAddress)
+		assert.Equal(t, 8888, connect.SidecarService.Proxy.LocalServicePort)
+		assert.Equal(t, []consulapi.Upstream{upstream}, connect.SidecarService.Proxy.Upstreams)
+	}
+	// the option is not changed by a registration
+	assert.Empty(t, o.connect.SidecarService.Address)
+	assert.Empty(t, o.connect.SidecarService.Proxy.LocalServiceAddress)
+}
+
+// TestConsulConnectNative tests resolving the Connect native services.
+func TestConsulConnectNative(t *testing.T) {
+	t.Parallel()
+	config := consulapi.DefaultConfig()
+	config.Address = consulAddr
+	consulClient, err := consulapi.NewClient(config)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	testSvcName := "hertz.test.connect"
+	native := &registry.Info{
+		ServiceName: testSvcName,
+		Addr:        utils.NewNetAddr("tcp", net.JoinHostPort(localIpAddr, "8587")),
+		Weight:      10,
+	}
+	plain := &registry.Info{
+		ServiceName: testSvcName,
+		Addr:        utils.NewNetAddr("tcp", net.JoinHostPort(localIpAddr, "8588")),
+		Weight:      10,
+	}
+	r := NewConsulRegister(consulClient, WithCheck(nil), WithConnectNative())
+	assert.Nil(t, r.Register(native))
+	defer r.Deregister(native)
+	r = NewConsulRegister(consulClient, WithCheck(nil))
+	assert.Nil(t, r.Register(plain))
+	defer r.Deregister(plain)
+
+	result, err := NewConsulResolver(consulClient, WithResolverConnect()).Resolve(context.Background(), testSvcName)
+	assert.Nil(t, err)
+	if assert.Len(t, result.Instances, 1) {
+		assert.Equal(t, net.JoinHostPort(localIpAddr, "8587"), result.Instances[0].Address().String())
+	}
+	result, err = cResolver.Resolve(context.Background(), testSvcName)
+	assert.Nil(t, err)
+	assert.Len(t, result.Instances, 2)
+}
+
+// TestConsulCatalogMode tests registering the services in the catalog without an agent.
+func TestConsulCatalogMode(t *testing.T) {
+	t.Parallel()
+	config := consulapi.DefaultConfig()
+	config.Address = consulAddr
+	consulClient, err := consulapi.NewClient(config)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	testSvcName := "hertz.test.catalog"
+	info := &registry.Info{
+		ServiceName: testSvcName,
+		Addr:        utils.NewNetAddr("tcp", net.JoinHostPort(localIpAddr, "8589")),
+		Weight:      10,
+		Tags:        map[string]string{"env": "prod"},
+	}
+	r := NewConsulRegister(consulClient, WithCatalogMode(""), WithCatalogHeartbeat(time.Second))
+	assert.Nil(t, r.Register(info))
+
+	svcID, err := getServiceId(info)
+	assert.Nil(t, err)
+	node, _, err := consulClient.Catalog().Node(catalogNodeName(svcID), nil)
+	assert.Nil(t, err)
+	if assert.NotNil(t, node) {
+		assert.Equal(t, "true", node.Node.Meta["external-node"])
+		assert.Contains(t, node.Services, svcID)
+	}
+
+	result, err := cResolver.Resolve(context.Background(), testSvcName)
+	assert.Nil(t, err)
+	if assert.Len(t, result.Instances, 1) {
+		assert.Equal(t, net.JoinHostPort(localIpAddr, "8589"), result.Instances[0].Address().String())
+		assert.Equal(t, 10, result.Instances[0].Weight())
+		env, _ := result.Instances[0].Tag("env")
+		assert.Equal(t, "prod", env)
+	}
+
+	// the heartbeat registers the service again after it is removed
+	_, err = consulClient.Catalog().Deregister(&consulapi.CatalogDeregistration{Node: catalogNodeName(svcID)}, nil)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		result, err = cResolver.Resolve(context.Background(), testSvcName)
+		return err == nil && len(result.Instances) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+
+	assert.Nil(t, r.(*consulRegistry).Drain(info))
+	result, err = cResolver.Resolve(context.Background(), testSvcName)
+	assert.Nil(t, err)
+	assert.Len(t, result.Instances, 0)
+
+	assert.Nil(t, r.Deregister(info))
+	node, _, err = consulClient.Catalog().Node(catalogNodeName(svcID), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, node)
+}
+
+func TestCatalogNodeName(t *testing.T) {
+	assert.Equal(t, "hertz-hertz-test-demo-10-0-0-1-8888", catalogNodeName("hertz.test.demo:10.0.0.1:8888"))
+}
@@ -15,18 +15,23 @@
 package consul
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"net"
+	"sync"
+	"time"
 
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/hashicorp/consul/api"
+	"github.com/hertz-contrib/registry/address"
 )
 
 const (
 	DefaultCheckInterval                       = "5s"
 	DefaultCheckTimeout                        = "5s"
 	DefaultCheckDeregisterCriticalServiceAfter = "1m"
+
+	drainReason = "hertz server is shutting down"
 )
 
 var (
@@ -38,33 +43,122 @@ var (
 type consulRegistry struct {
 	consulClient *api.Client
 	opts         options
+
+	mu sync.Mutex
+	// ttlCancels stops updating the TTL checks of every registered service
+	ttlCancels map[string]context.CancelFunc
+	// catalogEntries holds the services registered in catalog mode
+	catalogEntries map[string]*catalogEntry
 }
 
 var _ registry.Registry = (*consulRegistry)(nil)
 
 type options struct {
-	check *api.AgentServiceCheck
+	// check is the TCP check
+	check    *api.AgentServiceCheck
+	checkSet bool
+	checks   []serviceCheck
+
+	tagFormat   TagFormat
+	connect     *api.AgentServiceConnect
+	addressOpts []address.Option
+
+	token     string
+	namespace string
+	partition string
+
+	catalog          bool
+	catalogNode      string
+	catalogHeartbeat time.Duration
 }
 
 // Option is the option of Consul.
 type Option func(o *options)
 
-// WithCheck is consul registry option to set AgentServiceCheck.
+// WithCheck is consul registry option to set the AgentServiceCheck of the TCP check, nil disables it.
+// The TCP check is registered by default, unless other checks are added by WithHTTPCheck, WithGRPCCheck
+// or WithTTLCheck, in which case WithCheck is needed to keep it.
 func WithCheck(check *api.AgentServiceCheck) Option {
-	return func(o *options) { o.check = check }
+	return func(o *options) {
+		o.check = check
+		o.checkSet = true
+	}
+}
+
+// WithTagFormat is consul registry option to set how the tags of registry.Info are stored, TagFormatTags by default.
+func WithTagFormat(format TagFormat) Option {
+	return func(o *options) { o.tagFormat = format }
+}
+
+// WithConnect is consul registry option to register the service with the Connect block of consul service mesh.
+func WithConnect(connect *api.AgentServiceConnect) Option {
+	return func(o *options) { o.connect = connect }
+}
+
+// WithConnectNative is consul registry option to register the service as a Connect native service,
+// which serves the mTLS connections of the mesh itself.
+func WithConnectNative() Option {
+	return WithConnect(&api.AgentServiceConnect{Native: true})
+}
+
+// WithSidecarProxy is consul registry option to register a sidecar proxy of the service with the upstreams,
+// which is run separately, e.g. by `consul connect envoy -sidecar-for <service id>`.
+func WithSidecarProxy(upstreams ...api.Upstream) Option {
+	return WithConnect(&api.AgentServiceConnect{
+		SidecarService: &api.AgentServiceRegistration{
+			Proxy: &api.AgentServiceConnectProxyConfig{
+				Upstreams: upstreams,
+			},
+		},
+	})
+}
+
+// WithToken is consul registry option to set the ACL token of the requests, instead of the token of the client.
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// WithNamespace is consul registry option to set the namespace of the service, which is available in Consul Enterprise only.
+func WithNamespace(namespace string) Option {
+	return func(o *options) { o.namespace = namespace }
+}
+
+// WithPartition is consul registry option to set the admin partition of the service, which is available in Consul Enterprise only.
+func WithPartition(partition string) Option {
+	return func(o *options) { o.partition = partition }
+}
+
+// WithAddressOptions is consul registry option to set how the address to register is decided, see the address package.
+func WithAddressOptions(opts ...address.Option) Option {
+	return func(o *options) { o.addressOpts = append(o.addressOpts, opts...) }
 }
 
 // NewConsulRegister create a new registry using consul.
 func NewConsulRegister(consulClient *api.Client, opts ...Option) registry.Registry {
-	op := options{
-		check: defaultCheck(),
-	}
-
+	var op options
 	for _, opt := range opts {
 		opt(&op)
 	}
+	if !op.checkSet && len(op.checks) == 0 {
+		op.check = defaultCheck()
+	}
+
+	return &consulRegistry{
+		consulClient:   consulClient,
+		opts:           op,
+		ttlCancels:     make(map[string]context.CancelFunc),
+		catalogEntries: make(map[string]*catalogEntry),
+	}
+}
 
-	return &consulRegistry{consulClient: consulClient, opts: op}
+// NewConsulRegisterFromConfig create a new registry using consul, with a client built from config.
+// The address, TLS config, ACL token, namespace and partition of the client are set by config.
+func NewConsulRegisterFromConfig(config *api.Config, opts ...Option) (registry.Registry, error) {
+	consulClient, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating consul client failed, err: %w", err)
+	}
+	return NewConsulRegister(consulClient, opts...), nil
 }
 
 // Register register a service to consul.
@@ -73,39 +167,53 @@ func (c *consulRegistry) Register(info *registry.Info) error {
 		return fmt.Errorf("validating registry info failed, err: %w", err)
 	}
 
-	host, port, err := parseAddr(info.Addr)
+	host, port, err := parseAddr(info.Addr, c.opts.addressOpts...)
 	if err != nil {
 		return fmt.Errorf("parsing addr failed, err: %w", err)
 	}
 
-	svcID, err := getServiceId(info)
+	svcID, err := getServiceId(info, c.opts.addressOpts...)
 	if err != nil {
 		return fmt.Errorf("getting service id failed, err: %w", err)
 	}
 
-	tags, err := convTagMapToSlice(info.Tags)
+	tags, meta, err := convTagMap(info.Tags, c.opts.tagFormat)
 	if err != nil {
 		return err
 	}
 
+	weights := &api.AgentWeights{
+		Passing: info.Weight,
+		Warning: info.Weight,
+	}
 	svcInfo := &api.AgentServiceRegistration{
-		ID:      svcID,
-		Name:    info.ServiceName,
-		Address: host,
-		Port:    port,
-		Tags:    tags,
-		Weights: &api.AgentWeights{
-			Passing: info.Weight,
-			Warning: info.Weight,
-		},
-		Check: c.opts.check,
+		ID:        svcID,
+		Name:      info.ServiceName,
+		Address:   host,
+		Port:      port,
+		Tags:      tags,
+		Meta:      meta,
+		Namespace: c.opts.namespace,
+		Partition: c.opts.partition,
+		Weights:   weights,
+		Checks:    c.buildChecks(svcID, host, port),
+		Connect:   buildConnect(c.opts.connect, host, port, weights),
 	}
-	if c.opts.check != nil {
-		c.opts.check.TCP = net.JoinHostPort(host, fmt.Sprintf("%d", port))
-		svcInfo.Check = c.opts.check
+
+	if c.opts.catalog {
+		return c.registerCatalog(svcInfo)
 	}
 
-	return c.consulClient.Agent().ServiceRegister(svcInfo)
+	// replace the checks of the previous registration, which may have been built with other options
+	err = c.consulClient.Agent().ServiceRegisterOpts(svcInfo, api.ServiceRegisterOpts{
+		ReplaceExistingChecks: true,
+		Token:                 c.opts.token,
+	})
+	if err != nil {
+		return err
+	}
+	c.startTTLChecks(svcID)
+	return nil
 }
 
 // Deregister deregister a service from consul.
@@ -115,12 +223,75 @@ func (c *consulRegistry) Deregister(info *registry.Info) error {
 		return fmt.Errorf("validating registry info failed, err: %w", err)
 	}
 
-	svcID, err := getServiceId(info)
+	svcID, err := getServiceId(info, c.opts.addressOpts...)
 	if err != nil {
 		return err
 	}
 
-	return c.consulClient.Agent().ServiceDeregister(svcID)
+	if c.opts.catalog {
+		return c.deregisterCatalog(svcID)
+	}
+	c.stopTTLChecks(svcID)
+	return c.consulClient.Agent().ServiceDeregisterOpts(svcID, c.queryOptions())
+}
+
+// Drain puts a service into maintenance mode, so that it is no longer resolved while it is still registered.
+func (c *consulRegistry) Drain(info *registry.Info) error {
+	err := validateRegistryInfo(info)
+	if err != nil {
+		return fmt.Errorf("validating registry info failed, err: %w", err)
+	}
+
+	svcID, err := getServiceId(info, c.opts.addressOpts...)
+	if err != nil {
+		return err
+	}
+
+	if c.opts.catalog {
+		return c.drainCatalog(svcID)
+	}
+	return c.consulClient.Agent().EnableServiceMaintenanceOpts(svcID, drainReason, c.queryOptions())
+}
+
+// queryOptions returns the options of the requests to the agent, which are about the registered service.
+func (c *consulRegistry) queryOptions() *api.QueryOptions {
+	return &api.QueryOptions{
+		Token:     c.opts.token,
+		Namespace: c.opts.namespace,
+		Partition: c.opts.partition,
+	}
+}
+
+// buildConnect returns a copy of connect, with the sidecar proxy forwarding to host:port
+// and having the weights of the service if they are not set.
+func buildConnect(connect *api.AgentServiceConnect, host string, port int, weights *api.AgentWeights) *api.AgentServiceConnect {
+	if connect == nil {
+		return nil
+	}
+	c := *connect
+	if c.SidecarService == nil {
+		return &c
+	}
+	sidecar := *c.SidecarService
+	if sidecar.Address == "" {
+		sidecar.Address = host
+	}
+	if sidecar.Weights == nil {
+		sidecar.Weights = weights
+	}
+	proxy := api.AgentServiceConnectProxyConfig{}
+	if sidecar.Proxy != nil {
+		proxy = *sidecar.Proxy
+	}
+	if proxy.LocalServiceAddress == "" && proxy.LocalServiceSocketPath == "" {
+		proxy.LocalServiceAddress = host
+	}
+	if proxy.LocalServicePort == 0 && proxy.LocalServiceSocketPath == "" {
+		proxy.LocalServicePort = port
+	}
+	sidecar.Proxy = &proxy
+	c.SidecarService = &sidecar
+	return &c
 }
 
 func defaultCheck() *api.AgentServiceCheck {
@@ -16,31 +16,210 @@ package consul
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/hashicorp/consul/api"
 )
 
 const (
 	defaultNetwork = "tcp"
+
+	DefaultBlockingWaitTime = 5 * time.Minute
+	minRetryBackoff         = time.Second
+	maxRetryBackoff         = 30 * time.Second
+)
+
+// The keys of discovery.TargetInfo.Tags which select where the instances are resolved from,
+// the other tags select the instances having the tag `key:value`, or `key` if the value is empty.
+const (
+	// TagDatacenter selects the datacenter to query.
+	TagDatacenter = "datacenter"
+	// TagNamespace selects the namespace to query, which is available in Consul Enterprise only.
+	TagNamespace = "namespace"
+	// TagPartition selects the admin partition to query, which is available in Consul Enterprise only.
+	TagPartition = "partition"
+	// TagFilter is a filter expression of the instances, e.g. `Service.Meta.version == "v2"`.
+	// See https://developer.hashicorp.com/consul/api-docs/features/filtering
+	TagFilter = "filter"
 )
 
 type consulResolver struct {
 	consulClient *api.Client
+	opts         resolverOptions
+
+	mu       sync.Mutex
+	watchers map[string]*serviceWatcher
+	closed   bool
+	// wg waits for the goroutines running the blocking queries
+	wg sync.WaitGroup
+}
+
+var (
+	_ discovery.Resolver = (*consulResolver)(nil)
+	_ io.Closer          = (*consulResolver)(nil)
+)
+
+// ErrResolverClosed is returned by the resolver with blocking queries after it is closed.
+var ErrResolverClosed = errors.New("consul resolver is closed")
+
+type resolverOptions struct {
+	blocking  bool
+	waitTime  time.Duration
+	onChange  ChangeNotifyFunc
+	tagFormat TagFormat
+	connect   bool
+
+	token     string
+	namespace string
+	partition string
+}
+
+// ResolverOption is the option of consul resolver.
+type ResolverOption func(o *resolverOptions)
+
+// ChangeNotifyFunc is called with the description of a resolved service and its
+// latest instances whenever the instance set of the service changes.
+type ChangeNotifyFunc func(desc string, result discovery.Result)
+
+// WithBlockingQuery makes the resolver run a blocking query per service, waiting at most
+// waitTime for each one, and serve Resolve from the cached result of the query.
+func WithBlockingQuery(waitTime time.Duration) ResolverOption {
+	return func(o *resolverOptions) {
+		o.blocking = true
+		o.waitTime = waitTime
+	}
+}
+
+// WithChangeNotify sets the callback used by the resolver in blocking query mode
+// to report instance set changes.
+func WithChangeNotify(fn ChangeNotifyFunc) ResolverOption {
+	return func(o *resolverOptions) {
+		o.onChange = fn
+	}
+}
+
+// WithResolverTagFormat sets how the tags of the instances are read, which should be the format
+// used by the registries, TagFormatTags by default.
+func WithResolverTagFormat(format TagFormat) ResolverOption {
+	return func(o *resolverOptions) {
+		o.tagFormat = format
+	}
+}
+
+// WithResolverConnect makes the resolver resolve the Connect capable instances of consul service mesh,
+// which are the sidecar proxies of the services, or the Connect native services.
+func WithResolverConnect() ResolverOption {
+	return func(o *resolverOptions) {
+		o.connect = true
+	}
+}
+
+// WithResolverToken sets the ACL token of the queries, instead of the token of the client.
+func WithResolverToken(token string) ResolverOption {
+	return func(o *resolverOptions) {
+		o.token = token
+	}
+}
+
+// WithResolverNamespace sets the namespace to query, unless it is set by the TagNamespace of the target.
+// Namespaces are available in Consul Enterprise only.
+func WithResolverNamespace(namespace string) ResolverOption {
+	return func(o *resolverOptions) {
+		o.namespace = namespace
+	}
 }
 
-var _ discovery.Resolver = (*consulResolver)(nil)
+// WithResolverPartition sets the admin partition to query, unless it is set by the TagPartition of the target.
+// Admin partitions are available in Consul Enterprise only.
+func WithResolverPartition(partition string) ResolverOption {
+	return func(o *resolverOptions) {
+		o.partition = partition
+	}
+}
+
+// serviceWatcher caches the result of the blocking query of one service.
+type serviceWatcher struct {
+	desc   string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.RWMutex
+	lastIndex uint64
+	instances []discovery.Instance
+}
 
 // NewConsulResolver create a service resolver using consul.
-func NewConsulResolver(consulClient *api.Client) discovery.Resolver {
-	return &consulResolver{consulClient: consulClient}
+// The resolver implements io.Closer, whose Close stops the blocking queries started by WithBlockingQuery.
+func NewConsulResolver(consulClient *api.Client, opts ...ResolverOption) discovery.Resolver {
+	op := resolverOptions{
+		waitTime: DefaultBlockingWaitTime,
+	}
+
+	for _, opt := range opts {
+		opt(&op)
+	}
+	if op.waitTime <= 0 {
+		op.waitTime = DefaultBlockingWaitTime
+	}
+
+	return &consulResolver{
+		consulClient: consulClient,
+		opts:         op,
+		watchers:     make(map[string]*serviceWatcher),
+	}
+}
+
+// NewConsulResolverFromConfig create a service resolver using consul, with a client built from config.
+// The address, TLS config, ACL token, namespace and partition of the client are set by config.
+func NewConsulResolverFromConfig(config *api.Config, opts ...ResolverOption) (discovery.Resolver, error) {
+	consulClient, err := api.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating consul client failed, err: %w", err)
+	}
+	return NewConsulResolver(consulClient, opts...), nil
 }
 
 // Target return a description for the given target that is suitable for being a key for cache.
+// The tags of the target are encoded in the description as a query string, e.g. `service?datacenter=dc2&env=prod`.
 func (c *consulResolver) Target(_ context.Context, target *discovery.TargetInfo) (description string) {
-	return target.Host
+	if len(target.Tags) == 0 {
+		return target.Host
+	}
+	values := url.Values{}
+	for k, v := range target.Tags {
+		values.Set(k, v)
+	}
+	// Encode sorts by key, so that the same tags always have the same description
+	return target.Host + "?" + values.Encode()
+}
+
+// Close stops the blocking queries of the resolver, the consul client is left open.
+func (c *consulResolver) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	for _, w := range c.watchers {
+		w.cancel()
+	}
+	c.watchers = make(map[string]*serviceWatcher)
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	return nil
 }
 
 // Name returns the name of the resolver.
@@ -49,18 +228,75 @@ func (c *consulResolver) Name() string {
 }
 
 // Resolve a service info by desc.
-func (c *consulResolver) Resolve(_ context.Context, desc string) (discovery.Result, error) {
-	var eps []discovery.Instance
-	agentServiceList, _, err := c.consulClient.Health().Service(desc, "", true, nil)
+func (c *consulResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	if c.opts.blocking {
+		w, err := c.getWatcher(ctx, desc)
+		if err != nil {
+			return discovery.Result{}, err
+		}
+		return w.result(), nil
+	}
+
+	eps, _, err := c.query(desc, nil)
 	if err != nil {
 		return discovery.Result{}, err
 	}
+	return discovery.Result{
+		CacheKey:  desc,
+		Instances: eps,
+	}, nil
+}
+
+// query returns the passing instances of the service desc.
+func (c *consulResolver) query(desc string, q *api.QueryOptions) ([]discovery.Instance, *api.QueryMeta, error) {
+	sq, err := parseDesc(desc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if q == nil {
+		q = &api.QueryOptions{}
+	}
+	q.Datacenter = sq.datacenter
+	q.Namespace = sq.namespace
+	if q.Namespace == "" {
+		q.Namespace = c.opts.namespace
+	}
+	q.Partition = sq.partition
+	if q.Partition == "" {
+		q.Partition = c.opts.partition
+	}
+	q.Filter = sq.filter
+	q.Token = c.opts.token
+
+	var consulTags []string
+	if c.opts.tagFormat == TagFormatTags {
+		// filter by the consul tags, the tags in the Meta are matched below
+		consulTags, _ = convTagMapToSlice(sq.tags)
+		sort.Strings(consulTags)
+	}
+
+	var (
+		eps              []discovery.Instance
+		agentServiceList []*api.ServiceEntry
+		meta             *api.QueryMeta
+	)
+	if c.opts.connect {
+		agentServiceList, meta, err = c.consulClient.Health().ConnectMultipleTags(sq.service, consulTags, true, q)
+	} else {
+		agentServiceList, meta, err = c.consulClient.Health().ServiceMultipleTags(sq.service, consulTags, true, q)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 	for _, i := range agentServiceList {
 		svc := i.Service
 		if svc == nil || svc.Address == "" {
 			continue
 		}
-		tags := splitTags(svc.Tags)
+		tags := splitTagsAndMeta(svc.Tags, svc.Meta, c.opts.tagFormat)
+		if !matchTags(tags, sq.tags) {
+			continue
+		}
 		eps = append(eps, discovery.NewInstance(
 			defaultNetwork,
 			net.JoinHostPort(svc.Address, fmt.Sprintf("%d", svc.Port)),
@@ -68,9 +304,171 @@ func (c *consulResolver) Resolve(_ context.Context, desc string) (discovery.Resu
 			tags,
 		))
 	}
+	return eps, meta, nil
+}
+
+// serviceQuery is the query of the instances decoded from a description made by Target.
+type serviceQuery struct {
+	service    string
+	tags       map[string]string
+	datacenter string
+	namespace  string
+	partition  string
+	filter     string
+}
 
+func parseDesc(desc string) (serviceQuery, error) {
+	i := strings.Index(desc, "?")
+	if i < 0 {
+		return serviceQuery{service: desc}, nil
+	}
+	sq := serviceQuery{service: desc[:i]}
+	values, err := url.ParseQuery(desc[i+1:])
+	if err != nil {
+		return sq, fmt.Errorf("parsing description %s failed, err: %w", desc, err)
+	}
+	for k := range values {
+		v := values.Get(k)
+		switch k {
+		case TagDatacenter:
+			sq.datacenter = v
+		case TagNamespace:
+			sq.namespace = v
+		case TagPartition:
+			sq.partition = v
+		case TagFilter:
+			sq.filter = v
+		default:
+			if sq.tags == nil {
+				sq.tags = make(map[string]string)
+			}
+			sq.tags[k] = v
+		}
+	}
+	return sq, nil
+}
+
+// matchTags reports whether the instance having tags has all the tags of target.
+func matchTags(tags, target map[string]string) bool {
+	for k, v := range target {
+		if tv, ok := tags[k]; !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// getWatcher returns the watcher of desc, creating and starting it if needed.
+func (c *consulResolver) getWatcher(ctx context.Context, desc string) (*serviceWatcher, error) {
+	c.mu.Lock()
+	w, ok := c.watchers[desc]
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrResolverClosed
+	}
+	if ok {
+		return w, nil
+	}
+
+	eps, meta, err := c.query(desc, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	w = &serviceWatcher{
+		desc:      desc,
+		lastIndex: nextIndex(0, meta.LastIndex),
+		instances: eps,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrResolverClosed
+	}
+	// another goroutine may have started the watcher while we were querying
+	if exist, ok := c.watchers[desc]; ok {
+		return exist, nil
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	c.watchers[desc] = w
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.watch(w)
+	}()
+	return w, nil
+}
+
+// watch runs the blocking query of w until w.ctx is done.
+func (c *consulResolver) watch(w *serviceWatcher) {
+	backoff := minRetryBackoff
+	for {
+		w.mu.RLock()
+		index := w.lastIndex
+		w.mu.RUnlock()
+
+		q := &api.QueryOptions{
+			WaitIndex: index,
+			WaitTime:  c.opts.waitTime,
+		}
+		eps, meta, err := c.query(w.desc, q.WithContext(w.ctx))
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			hlog.Warnf("HERTZ: blocking query of service %s failed with err: %v, retry after %v", w.desc, err, backoff)
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxRetryBackoff {
+				backoff = maxRetryBackoff
+			}
+			continue
+		}
+		backoff = minRetryBackoff
+
+		if meta.LastIndex == index {
+			// the wait time is over without any change
+			continue
+		}
+
+		w.mu.Lock()
+		w.lastIndex = nextIndex(index, meta.LastIndex)
+		changed := !reflect.DeepEqual(w.instances, eps)
+		if changed {
+			w.instances = eps
+		}
+		w.mu.Unlock()
+
+		if changed && c.opts.onChange != nil {
+			c.opts.onChange(w.desc, w.result())
+		}
+	}
+}
+
+func (w *serviceWatcher) result() discovery.Result {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
 	return discovery.Result{
-		CacheKey:  desc,
-		Instances: eps,
-	}, nil
+		CacheKey:  w.desc,
+		Instances: w.instances,
+	}
+}
+
+// nextIndex returns the WaitIndex of the next blocking query.
+// See https://developer.hashicorp.com/consul/api-docs/features/blocking#implementation-details
+func nextIndex(prev, last uint64) uint64 {
+	// reset the index if it goes backwards
+	if last < prev {
+		return 0
+	}
+	// the index must be greater than zero, or the query will not block
+	if last == 0 {
+		return 1
+	}
+	return last
 }
@@ -18,48 +18,47 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"strconv"
 	"strings"
 
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
-	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/hertz-contrib/registry/address"
 )
 
-const kvJoinChar = ":"
+const (
+	kvJoinChar = ":"
 
-var errIllegalTagChar = errors.New("illegal tag character")
-
-func parseAddr(addr net.Addr) (host string, port int, err error) {
-	host, portStr, err := net.SplitHostPort(addr.String())
-	if err != nil {
-		return "", 0, fmt.Errorf("calling net.SplitHostPort failed, addr: %s, err: %w", addr.String(), err)
-	}
+	maxMetaKeyLength = 128
+)
 
-	if host == "" || host == "::" {
-		detectHost := utils.LocalIP()
-		if detectHost == utils.UNKNOWN_IP_ADDR {
-			return "", 0, fmt.Errorf("get local ip error")
-		}
+var (
+	errIllegalTagChar = errors.New("illegal tag character")
+	errIllegalMetaKey = errors.New("illegal meta key")
+)
 
-		host, _, err = net.SplitHostPort(detectHost)
-		if err != nil {
-			return "", 0, fmt.Errorf("empty host")
-		}
-	}
+// TagFormat is how the tags of registry.Info are stored in consul.
+type TagFormat int
 
-	port, err = strconv.Atoi(portStr)
-	if err != nil {
-		return "", 0, fmt.Errorf("parsing registry info port failed, portStr:%s, err: %w", portStr, err)
-	}
-	if port == 0 {
-		return "", 0, fmt.Errorf("invalid port %d", port)
-	}
+const (
+	// TagFormatTags stores the tags as consul tags `key:value`, or `key` if the value is empty.
+	// Keys must not contain `:`.
+	TagFormatTags TagFormat = iota
+	// TagFormatMeta stores the tags as the service Meta, which keeps the values as is.
+	// Keys may only contain ASCII letters, digits, `-` and `_`, see
+	// https://developer.hashicorp.com/consul/docs/services/configuration/services-configuration-reference#meta
+	TagFormatMeta
+	// TagFormatCompatible stores the tags in both formats, the tags whose keys are invalid in the Meta are stored
+	// as consul tags only.
+	// The resolver reads both formats, and the Meta takes precedence, so that the registries and the resolvers
+	// can be migrated from TagFormatTags to TagFormatMeta one by one.
+	TagFormatCompatible
+)
 
-	return host, port, nil
+func parseAddr(addr net.Addr, opts ...address.Option) (host string, port int, err error) {
+	return address.AdvertiseHostPort(addr, opts...)
 }
 
-func getServiceId(info *registry.Info) (string, error) {
-	host, port, err := parseAddr(info.Addr)
+func getServiceId(info *registry.Info, opts ...address.Option) (string, error) {
+	host, port, err := parseAddr(info.Addr, opts...)
 	if err != nil {
 		return "", err
 	}
@@ -85,6 +84,71 @@ func convTagMapToSlice(tagMap map[string]string) ([]string, error) {
 	return svcTags, nil
 }
 
+// convTagMapToMeta Tags map be converted to the service Meta.
+func convTagMapToMeta(tagMap map[string]string) (map[string]string, error) {
+	meta := make(map[string]string, len(tagMap))
+	for k, v := range tagMap {
+		if !validMetaKey(k) {
+			return nil, fmt.Errorf("%w: %s", errIllegalMetaKey, k)
+		}
+		meta[k] = v
+	}
+	return meta, nil
+}
+
+// convTagMap converts the tags map to the consul tags and the service Meta of format.
+func convTagMap(tagMap map[string]string, format TagFormat) (tags []string, meta map[string]string, err error) {
+	switch format {
+	case TagFormatMeta:
+		meta, err = convTagMapToMeta(tagMap)
+		return nil, meta, err
+	case TagFormatCompatible:
+		meta = make(map[string]string, len(tagMap))
+		for k, v := range tagMap {
+			if validMetaKey(k) {
+				meta[k] = v
+			}
+		}
+		tags, err = convTagMapToSlice(tagMap)
+		return tags, meta, err
+	default:
+		tags, err = convTagMapToSlice(tagMap)
+		return tags, nil, err
+	}
+}
+
+// splitTagsAndMeta converts the consul tags and the service Meta of format back to the tags map.
+func splitTagsAndMeta(tags []string, meta map[string]string, format TagFormat) map[string]string {
+	switch format {
+	case TagFormatMeta:
+		tagMap := make(map[string]string, len(meta))
+		for k, v := range meta {
+			tagMap[k] = v
+		}
+		return tagMap
+	case TagFormatCompatible:
+		tagMap := splitTags(tags)
+		for k, v := range meta {
+			tagMap[k] = v
+		}
+		return tagMap
+	default:
+		return splitTags(tags)
+	}
+}
+
+func validMetaKey(k string) bool {
+	if k == "" || len(k) > maxMetaKeyLength {
+		return false
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
 // splitTags Tags characters be separated to map.
 func splitTags(tags []string) map[string]string {
 	n := len(tags)
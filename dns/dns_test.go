@@ -0,0 +1,188 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	miekgdns "github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDNSServer starts an in-process nameserver answering from records, and returns its address.
+func setupDNSServer(t *testing.T, records map[uint16][]string, queries *int32) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	handler := miekgdns.HandlerFunc(func(w miekgdns.ResponseWriter, req *miekgdns.Msg) {
+		atomic.AddInt32(queries, 1)
+		m := new(miekgdns.Msg)
+		m.SetReply(req)
+		m.RecursionAvailable = true
+		q := req.Question[0]
+		found := false
+		for qtype, rrs := range records {
+			for _, s := range rrs {
+				rr, err := miekgdns.NewRR(s)
+				require.Nil(t, err)
+				if rr.Header().Name != q.Name {
+					continue
+				}
+				found = true
+				if qtype == q.Qtype {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+		}
+		if q.Qtype == miekgdns.TypeSRV {
+			// add the addresses of the targets as the additional records, as Consul DNS does
+			for _, rr := range m.Answer {
+				srv := rr.(*miekgdns.SRV)
+				for _, s := range records[miekgdns.TypeA] {
+					a, _ := miekgdns.NewRR(s)
+					if a.Header().Name == srv.Target {
+						m.Extra = append(m.Extra, a)
+					}
+				}
+			}
+		}
+		if !found {
+			m.Rcode = miekgdns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+	server := &miekgdns.Server{PacketConn: pc, Handler: handler}
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
+func TestResolveSRV(t *testing.T) {
+	var queries int32
+	addr := setupDNSServer(t, map[uint16][]string{
+		miekgdns.TypeSRV: {
+			"demo.service.consul. 60 IN SRV 1 10 8001 node1.node.consul.",
+			"demo.service.consul. 60 IN SRV 1 20 8002 node2.node.consul.",
+			"demo.service.consul. 60 IN SRV 2 30 8003 node3.node.consul.",
+		},
+		miekgdns.TypeA: {
+			"node1.node.consul. 30 IN A 10.0.0.1",
+			"node2.node.consul. 30 IN A 10.0.0.2",
+		},
+	}, &queries)
+	r, err := NewDNSResolver(WithNameserver(addr))
+	require.Nil(t, err)
+
+	desc := r.Target(context.Background(), &discovery.TargetInfo{Host: "demo.service.consul"})
+	res, err := r.Resolve(context.Background(), desc)
+	require.Nil(t, err)
+	assert.Equal(t, desc, res.CacheKey)
+	// only the records with the lowest priority are used
+	require.Len(t, res.Instances, 2)
+	assert.Equal(t, "10.0.0.1:8001", res.Instances[0].Address().String())
+	assert.Equal(t, 10, res.Instances[0].Weight())
+	assert.Equal(t, "10.0.0.2:8002", res.Instances[1].Address().String())
+	assert.Equal(t, 20, res.Instances[1].Weight())
+
+	// the result is cached until the TTL expires
+	n := atomic.LoadInt32(&queries)
+	_, err = r.Resolve(context.Background(), desc)
+	require.Nil(t, err)
+	assert.Equal(t, n, atomic.LoadInt32(&queries))
+	dr := r.(*dnsResolver)
+	dr.mu.Lock()
+	entry := dr.cache[desc]
+	dr.mu.Unlock()
+	require.NotNil(t, entry)
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), entry.expireAt, 2*time.Second)
+}
+
+func TestResolveA(t *testing.T) {
+	var queries int32
+	addr := setupDNSServer(t, map[uint16][]string{
+		miekgdns.TypeA: {
+			"demo.local. 0 IN A 10.0.0.1",
+			"demo.local. 0 IN A 10.0.0.2",
+		},
+		miekgdns.TypeAAAA: {
+			"demo.local. 0 IN AAAA ::1",
+		},
+	}, &queries)
+
+	// no SRV record and no port
+	r, err := NewDNSResolver(WithNameserver(addr))
+	require.Nil(t, err)
+	_, err = r.Resolve(context.Background(), "demo.local")
+	assert.NotNil(t, err)
+
+	// port in the target
+	res, err := r.Resolve(context.Background(), "demo.local:9000")
+	require.Nil(t, err)
+	require.Len(t, res.Instances, 3)
+	assert.Equal(t, "10.0.0.1:9000", res.Instances[0].Address().String())
+	assert.Equal(t, "[::1]:9000", res.Instances[2].Address().String())
+	for _, ins := range res.Instances {
+		assert.Equal(t, registry.DefaultWeight, ins.Weight())
+	}
+
+	// configured port
+	r, err = NewDNSResolver(WithNameserver(addr), WithPort(8888))
+	require.Nil(t, err)
+	res, err = r.Resolve(context.Background(), "demo.local")
+	require.Nil(t, err)
+	require.Len(t, res.Instances, 3)
+	assert.Equal(t, "10.0.0.2:8888", res.Instances[1].Address().String())
+
+	// a TTL of 0 is not cached
+	n := atomic.LoadInt32(&queries)
+	_, err = r.Resolve(context.Background(), "demo.local")
+	require.Nil(t, err)
+	assert.Greater(t, atomic.LoadInt32(&queries), n)
+}
+
+func TestResolveWithNetResolver(t *testing.T) {
+	var queries int32
+	addr := setupDNSServer(t, map[uint16][]string{
+		miekgdns.TypeA: {
+			"demo.local. 60 IN A 10.0.0.1",
+		},
+	}, &queries)
+	netResolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", addr)
+		},
+	}
+	r, err := NewDNSResolver(WithNetResolver(netResolver), WithPort(8888), WithDefaultTTL(time.Minute))
+	require.Nil(t, err)
+	res, err := r.Resolve(context.Background(), "demo.local")
+	require.Nil(t, err)
+	require.Len(t, res.Instances, 1)
+	assert.Equal(t, "10.0.0.1:8888", res.Instances[0].Address().String())
+}
@@ -0,0 +1,9 @@
+module github.com/hertz-contrib/registry/dns
+
+go 1.16
+
+require (
+	github.com/cloudwego/hertz v0.9.6
+	github.com/miekg/dns v1.1.50
+	github.com/stretchr/testify v1.10.0
+)
@@ -0,0 +1,210 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	miekgdns "github.com/miekg/dns"
+)
+
+type srvRecord struct {
+	target   string
+	port     uint16
+	priority uint16
+	weight   uint16
+}
+
+// lookuper looks up the records of a name, along with the TTL for caching them.
+type lookuper interface {
+	// lookupSRV returns the SRV records of name and the addresses of their targets which are known.
+	lookupSRV(ctx context.Context, name string) ([]srvRecord, map[string][]net.IP, time.Duration, error)
+	// lookupIP returns the addresses in the A/AAAA records of host.
+	lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+}
+
+// nameserverLookuper queries a nameserver directly, so that the TTL of the records is known.
+type nameserverLookuper struct {
+	udp  *miekgdns.Client
+	tcp  *miekgdns.Client
+	addr string
+}
+
+func newNameserverLookuper(addr string, timeout time.Duration) *nameserverLookuper {
+	return &nameserverLookuper{
+		udp:  &miekgdns.Client{Net: "udp", Timeout: timeout},
+		tcp:  &miekgdns.Client{Net: "tcp", Timeout: timeout},
+		addr: addr,
+	}
+}
+
+// query returns the answer of the question, or nil if the name does not exist.
+func (l *nameserverLookuper) query(ctx context.Context, name string, qtype uint16) (*miekgdns.Msg, error) {
+	m := new(miekgdns.Msg)
+	m.SetQuestion(miekgdns.Fqdn(name), qtype)
+	resp, _, err := l.udp.ExchangeContext(ctx, m, l.addr)
+	if err == nil && resp.Truncated {
+		resp, _, err = l.tcp.ExchangeContext(ctx, m, l.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Rcode {
+	case miekgdns.RcodeSuccess:
+		return resp, nil
+	case miekgdns.RcodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("query %s %s failed with rcode %s", name,
+			miekgdns.TypeToString[qtype], miekgdns.RcodeToString[resp.Rcode])
+	}
+}
+
+func (l *nameserverLookuper) lookupSRV(ctx context.Context, name string) ([]srvRecord, map[string][]net.IP, time.Duration, error) {
+	resp, err := l.query(ctx, name, miekgdns.TypeSRV)
+	if err != nil || resp == nil {
+		return nil, nil, 0, err
+	}
+	var (
+		srvs []srvRecord
+		ttl  = newMinTTL()
+	)
+	for _, rr := range resp.Answer {
+		if srv, ok := rr.(*miekgdns.SRV); ok {
+			srvs = append(srvs, srvRecord{
+				target:   trimDot(srv.Target),
+				port:     srv.Port,
+				priority: srv.Priority,
+				weight:   srv.Weight,
+			})
+			ttl.add(srv.Hdr.Ttl)
+		}
+	}
+	ips := make(map[string][]net.IP)
+	for _, rr := range resp.Extra {
+		switch a := rr.(type) {
+		case *miekgdns.A:
+			ips[trimDot(a.Hdr.Name)] = append(ips[trimDot(a.Hdr.Name)], a.A)
+			ttl.add(a.Hdr.Ttl)
+		case *miekgdns.AAAA:
+			ips[trimDot(a.Hdr.Name)] = append(ips[trimDot(a.Hdr.Name)], a.AAAA)
+			ttl.add(a.Hdr.Ttl)
+		}
+	}
+	return srvs, ips, ttl.value(), nil
+}
+
+func (l *nameserverLookuper) lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	var (
+		ips []net.IP
+		ttl = newMinTTL()
+	)
+	for _, qtype := range []uint16{miekgdns.TypeA, miekgdns.TypeAAAA} {
+		resp, err := l.query(ctx, host, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp == nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			switch a := rr.(type) {
+			case *miekgdns.A:
+				ips = append(ips, a.A)
+				ttl.add(a.Hdr.Ttl)
+			case *miekgdns.AAAA:
+				ips = append(ips, a.AAAA)
+				ttl.add(a.Hdr.Ttl)
+			}
+		}
+	}
+	return ips, ttl.value(), nil
+}
+
+// netLookuper looks up the records with a net.Resolver, which does not report the TTL.
+type netLookuper struct {
+	resolver *net.Resolver
+	ttl      time.Duration
+}
+
+func (l *netLookuper) lookupSRV(ctx context.Context, name string) ([]srvRecord, map[string][]net.IP, time.Duration, error) {
+	_, addrs, err := l.resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, 0, nil
+		}
+		return nil, nil, 0, err
+	}
+	srvs := make([]srvRecord, 0, len(addrs))
+	for _, addr := range addrs {
+		srvs = append(srvs, srvRecord{
+			target:   trimDot(addr.Target),
+			port:     addr.Port,
+			priority: addr.Priority,
+			weight:   addr.Weight,
+		})
+	}
+	return srvs, nil, l.ttl, nil
+}
+
+func (l *netLookuper) lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addrs, err := l.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, l.ttl, nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func trimDot(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
+
+// minTTL tracks the minimum TTL of a set of records.
+type minTTL struct {
+	ttl uint32
+	set bool
+}
+
+func newMinTTL() *minTTL {
+	return &minTTL{}
+}
+
+func (m *minTTL) add(ttl uint32) {
+	if !m.set || ttl < m.ttl {
+		m.ttl = ttl
+		m.set = true
+	}
+}
+
+func (m *minTTL) value() time.Duration {
+	return time.Duration(m.ttl) * time.Second
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	defaultTimeout    = 3 * time.Second
+	defaultTTL        = 30 * time.Second
+	defaultResolvConf = "/etc/resolv.conf"
+)
+
+type options struct {
+	nameserver  string
+	netResolver *net.Resolver
+	port        int
+	timeout     time.Duration
+	defaultTTL  time.Duration
+}
+
+// Option is the option of dns resolver.
+type Option func(o *options)
+
+// WithNameserver sets the address of the nameserver to query, e.g. "127.0.0.1:8600" for Consul DNS.
+// Default: the first nameserver in /etc/resolv.conf
+func WithNameserver(addr string) Option {
+	return func(o *options) {
+		o.nameserver = addr
+	}
+}
+
+// WithNetResolver sets the net.Resolver used to look up the records instead of querying a nameserver.
+// net.Resolver does not report the TTL of the records, so the results are cached for the default TTL.
+func WithNetResolver(r *net.Resolver) Option {
+	return func(o *options) {
+		o.netResolver = r
+	}
+}
+
+// WithPort sets the port of the instances resolved from A/AAAA records,
+// used when the target has no SRV record and no port.
+func WithPort(port int) Option {
+	return func(o *options) {
+		o.port = port
+	}
+}
+
+// WithTimeout sets the timeout of a query to the nameserver.
+// Default: 3s
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithDefaultTTL sets how long the results are cached when the TTL of the records is unknown.
+// Default: 30s
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.defaultTTL = ttl
+	}
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	miekgdns "github.com/miekg/dns"
+)
+
+const defaultNetwork = "tcp"
+
+var _ discovery.Resolver = (*dnsResolver)(nil)
+
+type dnsResolver struct {
+	lookup lookuper
+	opts   options
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	result   discovery.Result
+	expireAt time.Time
+}
+
+// NewDNSResolver creates a resolver which resolves the host of a target by its SRV records,
+// or by its A/AAAA records and the configured port if it has no SRV record.
+func NewDNSResolver(opts ...Option) (discovery.Resolver, error) {
+	o := options{
+		timeout:    defaultTimeout,
+		defaultTTL: defaultTTL,
+	}
+	o.apply(opts...)
+
+	var l lookuper
+	switch {
+	case o.netResolver != nil:
+		l = &netLookuper{resolver: o.netResolver, ttl: o.defaultTTL}
+	case o.nameserver != "":
+		l = newNameserverLookuper(o.nameserver, o.timeout)
+	default:
+		conf, err := miekgdns.ClientConfigFromFile(defaultResolvConf)
+		if err != nil {
+			return nil, fmt.Errorf("read nameserver from %s failed: %w", defaultResolvConf, err)
+		}
+		if len(conf.Servers) == 0 {
+			return nil, fmt.Errorf("no nameserver found in %s", defaultResolvConf)
+		}
+		l = newNameserverLookuper(net.JoinHostPort(conf.Servers[0], conf.Port), o.timeout)
+	}
+	return &dnsResolver{
+		lookup: l,
+		opts:   o,
+		cache:  make(map[string]*cacheEntry),
+	}, nil
+}
+
+// Target returns the host of the target, which may contain a port to skip the SRV lookup.
+func (d *dnsResolver) Target(_ context.Context, target *discovery.TargetInfo) string {
+	return target.Host
+}
+
+// Resolve returns the instances of desc, which are cached until the TTL of the records expires.
+func (d *dnsResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	d.mu.Lock()
+	entry, ok := d.cache[desc]
+	d.mu.Unlock()
+	if ok && time.Now().Before(entry.expireAt) {
+		return entry.result, nil
+	}
+
+	instances, ttl, err := d.resolve(ctx, desc)
+	if err != nil {
+		return discovery.Result{}, err
+	}
+	res := discovery.Result{
+		CacheKey:  desc,
+		Instances: instances,
+	}
+	d.mu.Lock()
+	if ttl > 0 && len(instances) > 0 {
+		d.cache[desc] = &cacheEntry{result: res, expireAt: time.Now().Add(ttl)}
+	} else {
+		delete(d.cache, desc)
+	}
+	d.mu.Unlock()
+	return res, nil
+}
+
+// Name returns the name of the resolver.
+func (d *dnsResolver) Name() string {
+	return "dns"
+}
+
+func (d *dnsResolver) resolve(ctx context.Context, desc string) ([]discovery.Instance, time.Duration, error) {
+	host, port, err := net.SplitHostPort(desc)
+	if err != nil {
+		// no port in desc, try the SRV records first
+		srvs, ips, ttl, err := d.lookup.lookupSRV(ctx, desc)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(srvs) > 0 {
+			return convertSRV(srvs, ips), ttl, nil
+		}
+		if d.opts.port <= 0 {
+			return nil, 0, fmt.Errorf("no SRV record found for %s and no port is configured", desc)
+		}
+		host, port = desc, strconv.Itoa(d.opts.port)
+	}
+
+	ips, ttl, err := d.lookup.lookupIP(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	instances := make([]discovery.Instance, 0, len(ips))
+	for _, ip := range ips {
+		// the A and AAAA records have no weight
+		instances = append(instances, discovery.NewInstance(defaultNetwork, net.JoinHostPort(ip.String(), port), registry.DefaultWeight, nil))
+	}
+	return instances, ttl, nil
+}
+
+// convertSRV returns the instances of the SRV records with the lowest priority,
+// weighted by the weight of the records. The target of a record is replaced by
+// its addresses if they are known.
+func convertSRV(srvs []srvRecord, ips map[string][]net.IP) []discovery.Instance {
+	priority := srvs[0].priority
+	for _, srv := range srvs {
+		if srv.priority < priority {
+			priority = srv.priority
+		}
+	}
+	var instances []discovery.Instance
+	for _, srv := range srvs {
+		if srv.priority != priority {
+			continue
+		}
+		// a weight of 0 means the lowest chance of being selected rather than the default weight
+		weight := int(srv.weight)
+		if weight == 0 {
+			weight = 1
+		}
+		port := strconv.Itoa(int(srv.port))
+		addrs, ok := ips[srv.target]
+		if !ok {
+			instances = append(instances, discovery.NewInstance(defaultNetwork, net.JoinHostPort(srv.target, port), weight, nil))
+			continue
+		}
+		for _, ip := range addrs {
+			instances = append(instances, discovery.NewInstance(defaultNetwork, net.JoinHostPort(ip.String(), port), weight, nil))
+		}
+	}
+	return instances
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drain deregisters the instances of a server gracefully, so that
+// the clients stop resolving an instance before it stops serving.
+package drain
+
+import (
+	"io"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+// Drainer is implemented by the registries which can mark an instance as not serving,
+// so that it is no longer resolved while it is still registered.
+type Drainer interface {
+	// Drain marks the registered instance of info as not serving.
+	Drain(info *registry.Info) error
+}
+
+var (
+	_ registry.Registry = (*drainRegistry)(nil)
+	_ io.Closer         = (*drainRegistry)(nil)
+)
+
+type drainRegistry struct {
+	registry.Registry
+	opts  options
+	sleep func(d time.Duration)
+}
+
+// NewDrainRegistry wraps r, so that Deregister drains the instance first if r is a Drainer,
+// waits for the drain period, and then deregisters it. If r is not a Drainer, the instance is
+// deregistered first and the drain period is waited afterwards, which keeps the server serving
+// the clients which have not refreshed their resolver cache yet.
+//
+// Hertz stops the server after Deregister returns, so the server keeps serving during the period.
+// The returned registry implements io.Closer, which closes r if r implements io.Closer.
+func NewDrainRegistry(r registry.Registry, opts ...Option) registry.Registry {
+	o := options{
+		period: defaultPeriod,
+	}
+	o.apply(opts...)
+	return &drainRegistry{
+		Registry: r,
+		opts:     o,
+		sleep:    time.Sleep,
+	}
+}
+
+// Deregister drains the instance, waits for the drain period and deregisters it.
+func (d *drainRegistry) Deregister(info *registry.Info) error {
+	drainer, ok := d.Registry.(Drainer)
+	if !ok {
+		err := d.Registry.Deregister(info)
+		if err == nil {
+			d.sleep(d.opts.period)
+		}
+		return err
+	}
+
+	if err := drainer.Drain(info); err != nil {
+		// deregister immediately, the instance can not be kept registered without serving
+		hlog.Warnf("HERTZ: drain instance failed with err: %v, deregister it immediately", err)
+		return d.Registry.Deregister(info)
+	}
+	d.sleep(d.opts.period)
+	return d.Registry.Deregister(info)
+}
+
+// Drain marks the instance as not serving if the wrapped registry is a Drainer.
+func (d *drainRegistry) Drain(info *registry.Info) error {
+	if drainer, ok := d.Registry.(Drainer); ok {
+		return drainer.Drain(info)
+	}
+	return nil
+}
+
+// Close closes the wrapped registry if it implements io.Closer.
+func (d *drainRegistry) Close() error {
+	if closer, ok := d.Registry.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drain
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordRegistry struct {
+	calls    []string
+	drainErr error
+}
+
+func (r *recordRegistry) Register(*registry.Info) error {
+	r.calls = append(r.calls, "register")
+	return nil
+}
+
+func (r *recordRegistry) Deregister(*registry.Info) error {
+	r.calls = append(r.calls, "deregister")
+	return nil
+}
+
+type drainableRegistry struct {
+	recordRegistry
+}
+
+func (r *drainableRegistry) Drain(*registry.Info) error {
+	r.calls = append(r.calls, "drain")
+	return r.drainErr
+}
+
+func newTestRegistry(r registry.Registry, calls *[]string) registry.Registry {
+	d := NewDrainRegistry(r, WithPeriod(time.Minute)).(*drainRegistry)
+	d.sleep = func(period time.Duration) {
+		*calls = append(*calls, "sleep "+period.String())
+	}
+	return d
+}
+
+func TestDrainRegistry(t *testing.T) {
+	info := &registry.Info{ServiceName: "hertz.test.demo", Addr: utils.NewNetAddr("tcp", "127.0.0.1:8888")}
+
+	r := &drainableRegistry{}
+	d := newTestRegistry(r, &r.calls)
+	assert.Nil(t, d.Register(info))
+	assert.Nil(t, d.Deregister(info))
+	assert.Equal(t, []string{"register", "drain", "sleep 1m0s", "deregister"}, r.calls)
+
+	// a failed drain deregisters immediately
+	r = &drainableRegistry{recordRegistry{drainErr: errors.New("drain failed")}}
+	d = newTestRegistry(r, &r.calls)
+	assert.Nil(t, d.Deregister(info))
+	assert.Equal(t, []string{"drain", "deregister"}, r.calls)
+}
+
+func TestDrainRegistryWithoutDrainer(t *testing.T) {
+	info := &registry.Info{ServiceName: "hertz.test.demo", Addr: utils.NewNetAddr("tcp", "127.0.0.1:8888")}
+
+	r := &recordRegistry{}
+	d := newTestRegistry(r, &r.calls)
+	assert.Nil(t, d.(Drainer).Drain(info))
+	assert.Nil(t, d.Deregister(info))
+	assert.Equal(t, []string{"deregister", "sleep 1m0s"}, r.calls)
+}
+
+type closableRegistry struct {
+	recordRegistry
+}
+
+func (r *closableRegistry) Close() error {
+	r.calls = append(r.calls, "close")
+	return nil
+}
+
+func TestDrainRegistryClose(t *testing.T) {
+	r := &closableRegistry{}
+	d := newTestRegistry(r, &r.calls)
+	assert.Nil(t, d.(io.Closer).Close())
+	assert.Equal(t, []string{"close"}, r.calls)
+
+	// closing a registry which is not an io.Closer is a no-op
+	assert.Nil(t, newTestRegistry(&recordRegistry{}, nil).(io.Closer).Close())
+}
@@ -0,0 +1,8 @@
+module github.com/hertz-contrib/registry/drain
+
+go 1.16
+
+require (
+	github.com/cloudwego/hertz v0.9.6
+	github.com/stretchr/testify v1.10.0
+)
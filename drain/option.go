@@ -0,0 +1,41 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drain
+
+import "time"
+
+const defaultPeriod = 10 * time.Second
+
+type options struct {
+	period time.Duration
+}
+
+// Option is the option of drain registry.
+type Option func(o *options)
+
+// WithPeriod sets how long to wait between draining and deregistering an instance,
+// which should cover the refresh interval of the resolver cache of the clients.
+// Default: 10s
+func WithPeriod(period time.Duration) Option {
+	return func(o *options) {
+		o.period = period
+	}
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+const (
+	// DefaultPrefix is the key prefix of the instances registered by hertz.
+	DefaultPrefix = "hertz/registry-etcd"
+	// KitexPrefix is the key prefix of the instances registered by the etcd registry of kitex.
+	KitexPrefix = "kitex/registry-etcd"
+)
+
+// InstanceInfo is the instance stored in etcd.
+type InstanceInfo struct {
+	Network string            `json:"network"`
+	Address string            `json:"address"`
+	Weight  int               `json:"weight"`
+	Tags    map[string]string `json:"tags"`
+	// Status is statusDraining when the instance is drained, and empty when it is serving.
+	Status string `json:"status,omitempty"`
+}
+
+// Codec decides the keys and the values of the instances in etcd.
+type Codec interface {
+	// Key returns the key of the instance of the service at addr.
+	Key(serviceName, addr string) string
+	// ServicePrefix returns the prefix of the keys of all the instances of the service, which is watched by the resolver.
+	ServicePrefix(serviceName string) string
+	// Marshal encodes the instance into the value of its key.
+	Marshal(info *InstanceInfo) ([]byte, error)
+	// Unmarshal decodes the value of an instance key.
+	Unmarshal(value []byte, info *InstanceInfo) error
+}
+
+type jsonCodec struct {
+	prefix string
+}
+
+// NewJSONCodec returns the codec storing the instances as JSON at `<prefix>/<service name>/<address>`,
+// which is the default codec with DefaultPrefix.
+func NewJSONCodec(prefix string) Codec {
+	return &jsonCodec{prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// NewKitexCodec returns the codec of the etcd registry of kitex, so that hertz and kitex can discover each other.
+func NewKitexCodec() Codec {
+	return NewJSONCodec(KitexPrefix)
+}
+
+func (c *jsonCodec) Key(serviceName, addr string) string {
+	return c.ServicePrefix(serviceName) + addr
+}
+
+func (c *jsonCodec) ServicePrefix(serviceName string) string {
+	return c.prefix + "/" + serviceName + "/"
+}
+
+func (c *jsonCodec) Marshal(info *InstanceInfo) ([]byte, error) {
+	return sonic.Marshal(info)
+}
+
+func (c *jsonCodec) Unmarshal(value []byte, info *InstanceInfo) error {
+	return sonic.Unmarshal(value, info)
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ConflictError is returned by Register with WithConflictDetection, when the key of the service
+// is registered by another registry whose lease is alive, e.g. two processes advertise the same address.
+type ConflictError struct {
+	Key string
+	// LeaseID is the lease of the existing registration, clientv3.NoLease if it has no lease
+	LeaseID clientv3.LeaseID
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("instance{%s} is registered by another registry with lease %x", e.Key, e.LeaseID)
+}
+
+// putExclusive puts key with leaseID only if key does not exist, is registered with leaseID already,
+// or is left by a lease which has expired. Otherwise, it returns a *ConflictError.
+func (e *etcdRegistry) putExclusive(key, val string, leaseID clientv3.LeaseID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	resp, err := e.etcdClient.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, val, clientv3.WithLease(leaseID))).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if resp.Succeeded {
+		return nil
+	}
+	kvs := resp.Responses[0].GetResponseRange().Kvs
+	if len(kvs) == 0 {
+		// deleted meanwhile, which is a conflict with the registry deleting it
+		return &ConflictError{Key: key, LeaseID: clientv3.NoLease}
+	}
+	owner := clientv3.LeaseID(kvs[0].Lease)
+	if owner != leaseID {
+		alive, err := e.leaseAlive(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if alive {
+			return &ConflictError{Key: key, LeaseID: owner}
+		}
+	}
+
+	// take over the key, unless it is modified meanwhile
+	resp, err = e.etcdClient.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", kvs[0].ModRevision)).
+		Then(clientv3.OpPut(key, val, clientv3.WithLease(leaseID))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return &ConflictError{Key: key, LeaseID: owner}
+	}
+	return nil
+}
+
+// putAllExclusive puts kvs with leaseID in one transaction if none of the keys exists,
+// otherwise the keys are put by putExclusive one by one. The keys registered by the others
+// are skipped and returned as conflicts, so that they do not fail the other keys.
+func (e *etcdRegistry) putAllExclusive(kvs map[string]string, leaseID clientv3.LeaseID) ([]*ConflictError, error) {
+	if len(kvs) <= maxTxnOps {
+		cmps := make([]clientv3.Cmp, 0, len(kvs))
+		ops := make([]clientv3.Op, 0, len(kvs))
+		for key, val := range kvs {
+			cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))
+			ops = append(ops, clientv3.OpPut(key, val, clientv3.WithLease(leaseID)))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		resp, err := e.etcdClient.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if resp.Succeeded {
+			return nil, nil
+		}
+	}
+	var conflicts []*ConflictError
+	for key, val := range kvs {
+		err := e.putExclusive(key, val, leaseID)
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return conflicts, nil
+}
+
+// dropConflicts removes the keys registered by the others from the registrations,
+// which must be called with e.mu held.
+func (e *etcdRegistry) dropConflicts(conflicts []*ConflictError) {
+	for _, conflict := range conflicts {
+		hlog.Errorf("HERTZ: %v, it is not registered any more", conflict)
+		delete(e.kvs, conflict.Key)
+	}
+}
+
+// notifyConflicts reports the keys dropped by dropConflicts.
+func (e *etcdRegistry) notifyConflicts(conflicts []*ConflictError) {
+	for _, conflict := range conflicts {
+		e.notify(Event{Type: EventConflict, Key: conflict.Key, Err: conflict})
+	}
+}
+
+// deleteExclusive deletes key only if it is registered with leaseID,
+// so that the registration of another registry is never deleted.
+func (e *etcdRegistry) deleteExclusive(key string, leaseID clientv3.LeaseID) error {
+	if leaseID == clientv3.NoLease {
+		// the keys are deleted with the lost lease
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_, err := e.etcdClient.Txn(ctx).
+		If(clientv3.Compare(clientv3.LeaseValue(key), "=", leaseID)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	return err
+}
+
+// leaseAlive reports whether the lease has not expired. A key without lease never expires.
+func (e *etcdRegistry) leaseAlive(ctx context.Context, leaseID clientv3.LeaseID) (bool, error) {
+	if leaseID == clientv3.NoLease {
+		return true, nil
+	}
+	resp, err := e.etcdClient.TimeToLive(ctx, leaseID)
+	if err != nil {
+		return false, err
+	}
+	return resp.TTL > 0, nil
+}
@@ -17,9 +17,12 @@ package etcd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -34,6 +37,7 @@ import (
 	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/stretchr/testify/assert"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/server/v3/embed"
@@ -42,6 +46,8 @@ import (
 var (
 	etcdCli *clientv3.Client
 	timeout time.Duration = time.Second * 2
+	// serviceKey is the key of an instance registered with the default codec
+	serviceKey = NewJSONCodec(DefaultPrefix).Key
 )
 
 func init() {
@@ -111,7 +117,7 @@ func TestRegistry(t *testing.T) {
 			cancel()
 
 			val := kv.Kvs[0].Value
-			en := new(instanceInfo)
+			en := new(InstanceInfo)
 			if err := json.Unmarshal(val, en); err != nil {
 				t.Errorf("json unmarshal error")
 			}
@@ -186,7 +192,7 @@ func TestResolver(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
 			key := serviceKey(info.ServiceName, args.Addr)
 			addr := utils.NewNetAddr("tcp", args.Addr)
-			content, err := json.Marshal(&instanceInfo{
+			content, err := json.Marshal(&InstanceInfo{
 				Network: addr.Network(),
 				Address: args.Addr,
 				Weight:  args.Weight,
@@ -332,11 +338,11 @@ func TestEtcdRegistryWithAddressBlank(t *testing.T) {
 
 func TestEtcdRegistryWithEnvironmentVariable(t *testing.T) {
 	s, endpoint := setupEmbedEtcd(t)
-	err := os.Setenv(hertzPortToRegistry, "8899")
+	err := os.Setenv(address.EnvPortToRegistry, "8899")
 	if err != nil {
 		return
 	}
-	err = os.Setenv(hertzIpToRegistry, "127.0.0.2")
+	err = os.Setenv(address.EnvIPToRegistry, "127.0.0.2")
 	if err != nil {
 		return
 	}
@@ -391,17 +397,532 @@ func TestEtcdRegistryWithEnvironmentVariable(t *testing.T) {
 			require.Nil(t, err)
 		}
 	}
-	os.Unsetenv(hertzPortToRegistry)
-	os.Unsetenv(hertzIpToRegistry)
+	os.Unsetenv(address.EnvPortToRegistry)
+	os.Unsetenv(address.EnvIPToRegistry)
 	teardownEmbedEtcd(s)
 }
 
+func TestEtcdRegistryMultipleServices(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	rg, err := NewEtcdRegistry([]string{endpoint})
+	require.Nil(t, err)
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	require.Nil(t, err)
+	defer cli.Close()
+
+	infoList := []*registry.Info{
+		{
+			ServiceName: "registry-etcd-http",
+			Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+			Weight:      10,
+		},
+		{
+			ServiceName: "registry-etcd-admin",
+			Addr:        utils.NewNetAddr("tcp", "127.0.0.1:9999"),
+			Weight:      10,
+		},
+	}
+	keys := make([]string, 0, len(infoList))
+	for _, info := range infoList {
+		require.Nil(t, rg.Register(info))
+		keys = append(keys, serviceKey(info.ServiceName, info.Addr.String()))
+	}
+
+	leaseOf := func(key string) int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		resp, err := cli.Get(ctx, key)
+		require.Nil(t, err)
+		if len(resp.Kvs) == 0 {
+			return 0
+		}
+		return resp.Kvs[0].Lease
+	}
+	// all the keys share the lease of the registry
+	lease := leaseOf(keys[0])
+	require.NotZero(t, lease)
+	require.Equal(t, lease, leaseOf(keys[1]))
+
+	// deregister the first service only
+	require.Nil(t, rg.Deregister(infoList[0]))
+	require.Zero(t, leaseOf(keys[0]))
+	require.Equal(t, lease, leaseOf(keys[1]))
+
+	reg := rg.(*etcdRegistry)
+	reg.mu.Lock()
+	_, ok := reg.kvs[keys[0]]
+	require.False(t, ok)
+	_, ok = reg.kvs[keys[1]]
+	require.True(t, ok)
+	require.Equal(t, clientv3.LeaseID(lease), reg.leaseID)
+	reg.mu.Unlock()
+
+	// the lease is revoked with the last key
+	require.Nil(t, rg.Deregister(infoList[1]))
+	require.Zero(t, leaseOf(keys[1]))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ttl, err := cli.TimeToLive(ctx, clientv3.LeaseID(lease))
+	require.Nil(t, err)
+	require.Equal(t, int64(-1), ttl.TTL)
+	reg.mu.Lock()
+	require.Equal(t, clientv3.NoLease, reg.leaseID)
+	reg.mu.Unlock()
+}
+
+func TestEtcdRegistryLeaseLost(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	// the lease loss is found by the next keepalive, which is sent every ttl/3
+	require.Nil(t, os.Setenv(ttlKey, "3"))
+	defer os.Unsetenv(ttlKey)
+	var (
+		mu     sync.Mutex
+		events []EventType
+	)
+	rg, err := NewEtcdRegistry([]string{endpoint},
+		WithRetryDelay(100*time.Millisecond),
+		WithEventHandler(func(event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event.Type)
+		}),
+	)
+	require.Nil(t, err)
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	require.Nil(t, err)
+	defer cli.Close()
+
+	infoList := []*registry.Info{
+		{
+			ServiceName: "registry-etcd-http",
+			Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+			Weight:      10,
+		},
+		{
+			ServiceName: "registry-etcd-admin",
+			Addr:        utils.NewNetAddr("tcp", "127.0.0.1:9999"),
+			Weight:      10,
+		},
+	}
+	keys := make([]string, 0, len(infoList))
+	for _, info := range infoList {
+		require.Nil(t, rg.Register(info))
+		keys = append(keys, serviceKey(info.ServiceName, info.Addr.String()))
+	}
+	defer func() {
+		for _, info := range infoList {
+			require.Nil(t, rg.Deregister(info))
+		}
+	}()
+
+	leaseOf := func(key string) int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		resp, err := cli.Get(ctx, key)
+		require.Nil(t, err)
+		if len(resp.Kvs) == 0 {
+			return 0
+		}
+		return resp.Kvs[0].Lease
+	}
+	lease := leaseOf(keys[0])
+	require.NotZero(t, lease)
+
+	// revoking the lease deletes the keys, which are put again with a new lease
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = cli.Revoke(ctx, clientv3.LeaseID(lease))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		first, second := leaseOf(keys[0]), leaseOf(keys[1])
+		return first != 0 && first != lease && first == second
+	}, 10*time.Second, 50*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []EventType{EventRegistered, EventRegistered, EventLeaseLost, EventReregistered}, events)
+}
+
+func TestEtcdRegistryRegisterWhileRecovering(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	require.Nil(t, os.Setenv(ttlKey, "3"))
+	defer os.Unsetenv(ttlKey)
+	first := &registry.Info{
+		ServiceName: "registry-etcd-http",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+		Weight:      10,
+	}
+	second := &registry.Info{
+		ServiceName: "registry-etcd-admin",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:9999"),
+		Weight:      10,
+	}
+	var rg registry.Registry
+	registered := make(chan error, 1)
+	rg, err := NewEtcdRegistry([]string{endpoint},
+		WithRetryDelay(100*time.Millisecond),
+		WithEventHandler(func(event Event) {
+			if event.Type == EventLeaseLost {
+				// the lost lease is not recovered yet
+				registered <- rg.Register(second)
+			}
+		}),
+	)
+	require.Nil(t, err)
+	defer rg.(io.Closer).Close()
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	require.Nil(t, err)
+	defer cli.Close()
+
+	require.Nil(t, rg.Register(first))
+	leaseOf := func(info *registry.Info) int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		resp, err := cli.Get(ctx, serviceKey(info.ServiceName, info.Addr.String()))
+		require.Nil(t, err)
+		if len(resp.Kvs) == 0 {
+			return 0
+		}
+		return resp.Kvs[0].Lease
+	}
+	lease := leaseOf(first)
+	require.NotZero(t, lease)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = cli.Revoke(ctx, clientv3.LeaseID(lease))
+	require.Nil(t, err)
+
+	// Register grants a new lease for every key instead of using the lost one
+	select {
+	case err = <-registered:
+		require.Nil(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the lease loss is not found")
+	}
+	newLease := leaseOf(first)
+	assert.NotZero(t, newLease)
+	assert.NotEqual(t, lease, newLease)
+	assert.Equal(t, newLease, leaseOf(second))
+}
+
+func TestEtcdResolverWatch(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	changed := make(chan discovery.Result, 10)
+	rg, err := NewEtcdRegistry([]string{endpoint})
+	require.Nil(t, err)
+	rs, err := NewEtcdResolver([]string{endpoint}, WithChangeNotify(func(desc string, result discovery.Result) {
+		changed <- result
+	}))
+	require.Nil(t, err)
+
+	info := &registry.Info{
+		ServiceName: "registry-etcd-watch",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+		Weight:      27,
+		Tags:        map[string]string{"hello": "world"},
+	}
+	desc := rs.Target(context.TODO(), &discovery.TargetInfo{Host: info.ServiceName})
+
+	// the first resolve loads the snapshot and starts watching
+	result, err := rs.Resolve(context.TODO(), desc)
+	require.Nil(t, err)
+	require.Empty(t, result.Instances)
+
+	require.Nil(t, rg.Register(info))
+	select {
+	case result = <-changed:
+	case <-time.After(timeout):
+		t.Fatal("register is not notified")
+	}
+	require.Equal(t, desc, result.CacheKey)
+	require.Len(t, result.Instances, 1)
+	require.Equal(t, "127.0.0.1:8888", result.Instances[0].Address().String())
+
+	result, err = rs.Resolve(context.TODO(), desc)
+	require.Nil(t, err)
+	require.Len(t, result.Instances, 1)
+	require.Equal(t, 27, result.Instances[0].Weight())
+
+	require.Nil(t, rg.Deregister(info))
+	select {
+	case result = <-changed:
+	case <-time.After(timeout):
+		t.Fatal("deregister is not notified")
+	}
+	require.Empty(t, result.Instances)
+
+	result, err = rs.Resolve(context.TODO(), desc)
+	require.Nil(t, err)
+	require.Empty(t, result.Instances)
+}
+
+func TestEtcdRegistryDrain(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	rg, err := NewEtcdRegistry([]string{endpoint})
+	require.Nil(t, err)
+	rs, err := NewEtcdResolver([]string{endpoint})
+	require.Nil(t, err)
+
+	info := &registry.Info{
+		ServiceName: "registry-etcd-drain",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+		Weight:      10,
+	}
+	desc := rs.Target(context.TODO(), &discovery.TargetInfo{Host: info.ServiceName})
+
+	// only registered instances can be drained
+	require.NotNil(t, rg.(interface{ Drain(*registry.Info) error }).Drain(info))
+
+	require.Nil(t, rg.Register(info))
+	result, err := rs.Resolve(context.TODO(), desc)
+	require.Nil(t, err)
+	require.Len(t, result.Instances, 1)
+
+	require.Nil(t, rg.(interface{ Drain(*registry.Info) error }).Drain(info))
+	require.Eventually(t, func() bool {
+		result, err = rs.Resolve(context.TODO(), desc)
+		return err == nil && len(result.Instances) == 0
+	}, timeout, 100*time.Millisecond)
+
+	// the drained instance is still registered
+	resp, err := rg.(*etcdRegistry).etcdClient.Get(context.TODO(), serviceKey(info.ServiceName, "127.0.0.1:8888"))
+	require.Nil(t, err)
+	require.Len(t, resp.Kvs, 1)
+
+	require.Nil(t, rg.Deregister(info))
+}
+
+func TestEtcdWithClient(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	require.Nil(t, err)
+	defer cli.Close()
+
+	rg := NewEtcdRegistryWithClient(cli)
+	rs := NewEtcdResolverWithClient(cli)
+	info := &registry.Info{
+		ServiceName: "registry-etcd-client",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+		Weight:      10,
+	}
+	require.Nil(t, rg.Register(info))
+	result, err := rs.Resolve(context.Background(), info.ServiceName)
+	require.Nil(t, err)
+	require.Len(t, result.Instances, 1)
+
+	// closing the registry deregisters the service, but leaves the shared client open
+	require.Nil(t, rg.(io.Closer).Close())
+	require.Nil(t, rs.(io.Closer).Close())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := cli.Get(ctx, serviceKey(info.ServiceName, info.Addr.String()))
+	require.Nil(t, err)
+	require.Len(t, resp.Kvs, 0)
+
+	require.Equal(t, ErrClosed, rg.Register(info))
+	_, err = rs.Resolve(context.Background(), info.ServiceName)
+	require.Equal(t, ErrClosed, err)
+	// closing again is a no-op
+	require.Nil(t, rg.(io.Closer).Close())
+}
+
+func TestEtcdCloseOwnedClient(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	rg, err := NewEtcdRegistry([]string{endpoint})
+	require.Nil(t, err)
+	rs, err := NewEtcdResolver([]string{endpoint})
+	require.Nil(t, err)
+	require.Nil(t, rg.(io.Closer).Close())
+	require.Nil(t, rs.(io.Closer).Close())
+	require.Error(t, rg.(*etcdRegistry).etcdClient.Ctx().Err())
+	require.Error(t, rs.(*etcdResolver).etcdClient.Ctx().Err())
+}
+
+func TestResolverOption(t *testing.T) {
+	o := newOptionForResolver([]string{"127.0.0.1:2345"}, WithAuthOpt("user", "pass"))
+	assert.Equal(t, []string{"127.0.0.1:2345"}, o.etcdCfg.Endpoints)
+	assert.Equal(t, "user", o.etcdCfg.Username)
+	assert.Equal(t, "pass", o.etcdCfg.Password)
+	assert.Equal(t, NewJSONCodec(DefaultPrefix), o.codec)
+}
+
+func TestEtcdRegistryConflict(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	require.Nil(t, err)
+	defer cli.Close()
+
+	first := NewEtcdRegistryWithClient(cli, WithConflictDetection())
+	second := NewEtcdRegistryWithClient(cli, WithConflictDetection())
+	defer first.(io.Closer).Close()
+	defer second.(io.Closer).Close()
+	info := &registry.Info{
+		ServiceName: "registry-etcd-conflict",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+		Weight:      10,
+	}
+	key := serviceKey(info.ServiceName, info.Addr.String())
+	leaseOf := func() int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		resp, err := cli.Get(ctx, key)
+		require.Nil(t, err)
+		if len(resp.Kvs) == 0 {
+			return 0
+		}
+		return resp.Kvs[0].Lease
+	}
+
+	require.Nil(t, first.Register(info))
+	// registering again with the same registry is not a conflict
+	require.Nil(t, first.Register(info))
+	lease := leaseOf()
+	require.NotZero(t, lease)
+
+	err = second.Register(info)
+	var conflict *ConflictError
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, key, conflict.Key)
+	require.Equal(t, clientv3.LeaseID(lease), conflict.LeaseID)
+	require.Equal(t, lease, leaseOf())
+
+	// the lease of the first registry expires, then the second one takes the key over
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = cli.Revoke(ctx, clientv3.LeaseID(lease))
+	require.Nil(t, err)
+	require.Nil(t, second.Register(info))
+	taken := leaseOf()
+	require.NotZero(t, taken)
+	require.NotEqual(t, lease, taken)
+
+	// the first registry does not delete the key of the second one
+	require.Nil(t, first.Deregister(info))
+	require.Equal(t, taken, leaseOf())
+	require.Nil(t, second.Deregister(info))
+	require.Zero(t, leaseOf())
+}
+
+func TestEtcdRegistryConflictOnRecovery(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	require.Nil(t, err)
+	defer cli.Close()
+
+	// the lease loss is found by the next keepalive, which is sent every ttl/3
+	require.Nil(t, os.Setenv(ttlKey, "3"))
+	defer os.Unsetenv(ttlKey)
+
+	var (
+		mu        sync.Mutex
+		conflicts []string
+	)
+	first := NewEtcdRegistryWithClient(cli,
+		WithConflictDetection(),
+		WithRetryDelay(100*time.Millisecond),
+		WithEventHandler(func(event Event) {
+			if event.Type != EventConflict {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			conflicts = append(conflicts, event.Key)
+		}),
+	)
+	second := NewEtcdRegistryWithClient(cli, WithConflictDetection())
+	defer first.(io.Closer).Close()
+	defer second.(io.Closer).Close()
+
+	infoList := []*registry.Info{
+		{
+			ServiceName: "registry-etcd-http",
+			Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+			Weight:      10,
+		},
+		{
+			ServiceName: "registry-etcd-admin",
+			Addr:        utils.NewNetAddr("tcp", "127.0.0.1:9999"),
+			Weight:      10,
+		},
+	}
+	keys := make([]string, 0, len(infoList))
+	for _, info := range infoList {
+		require.Nil(t, first.Register(info))
+		keys = append(keys, serviceKey(info.ServiceName, info.Addr.String()))
+	}
+	leaseOf := func(key string) int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		resp, err := cli.Get(ctx, key)
+		require.Nil(t, err)
+		if len(resp.Kvs) == 0 {
+			return 0
+		}
+		return resp.Kvs[0].Lease
+	}
+	lease := leaseOf(keys[0])
+	require.NotZero(t, lease)
+
+	// the lease of the first registry is lost, and the second one takes the first key over meanwhile
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = cli.Revoke(ctx, clientv3.LeaseID(lease))
+	require.Nil(t, err)
+	require.Nil(t, second.Register(infoList[0]))
+	taken := leaseOf(keys[0])
+	require.NotZero(t, taken)
+
+	// the other key is registered again with a new lease
+	require.Eventually(t, func() bool {
+		recovered := leaseOf(keys[1])
+		return recovered != 0 && recovered != lease && recovered != taken
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, taken, leaseOf(keys[0]))
+
+	// the conflict is reported once the other keys are put
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(conflicts) > 0
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{keys[0]}, conflicts)
+	mu.Unlock()
+	reg := first.(*etcdRegistry)
+	reg.mu.Lock()
+	_, ok := reg.kvs[keys[0]]
+	assert.False(t, ok)
+	_, ok = reg.kvs[keys[1]]
+	assert.True(t, ok)
+	reg.mu.Unlock()
+}
+
 func TestRetryOption(t *testing.T) {
 	o := newOptionForServer([]string{"127.0.0.1:2345"})
 	assert.Equal(t, o.etcdCfg.Endpoints, []string{"127.0.0.1:2345"})
 	assert.Equal(t, uint(5), o.retryCfg.maxAttemptTimes)
 	assert.Equal(t, 30*time.Second, o.retryCfg.observeDelay)
 	assert.Equal(t, 10*time.Second, o.retryCfg.retryDelay)
+	assert.Equal(t, 10*time.Second, o.retryCfg.delay(3))
 }
 
 func TestRetryCustomConfig(t *testing.T) {
@@ -416,6 +937,29 @@ func TestRetryCustomConfig(t *testing.T) {
 	assert.Equal(t, 5*time.Second, o.retryCfg.retryDelay)
 }
 
+func TestRetryBackoff(t *testing.T) {
+	o := newOptionForServer(
+		[]string{"127.0.0.1:2345"},
+		WithNeverGiveUp(),
+		WithBackoff(time.Second, 5*time.Second, 2, 0),
+	)
+	assert.Equal(t, uint(0), o.retryCfg.maxAttemptTimes)
+	assert.Equal(t, time.Second, o.retryCfg.delay(1))
+	assert.Equal(t, 2*time.Second, o.retryCfg.delay(2))
+	assert.Equal(t, 4*time.Second, o.retryCfg.delay(3))
+	assert.Equal(t, 5*time.Second, o.retryCfg.delay(4))
+	assert.Equal(t, 5*time.Second, o.retryCfg.delay(100))
+
+	o = newOptionForServer(
+		[]string{"127.0.0.1:2345"},
+		WithBackoff(time.Second, time.Minute, 2, 0.5),
+	)
+	for i := 0; i < 100; i++ {
+		d := o.retryCfg.delay(2)
+		assert.True(t, d >= time.Second && d <= 3*time.Second)
+	}
+}
+
 func setupEmbedEtcd(t *testing.T) (*embed.Etcd, string) {
 	pid := os.Getpid()
 	endpoint := fmt.Sprintf("http://localhost:%06d", pid)
@@ -449,3 +993,50 @@ func teardownEmbedEtcd(s *embed.Etcd) {
 	s.Close()
 	_ = os.RemoveAll(s.Config().Dir)
 }
+
+func TestCodec(t *testing.T) {
+	codec := NewJSONCodec(DefaultPrefix)
+	assert.Equal(t, "hertz/registry-etcd/hertz.test.demo/127.0.0.1:8888", codec.Key("hertz.test.demo", "127.0.0.1:8888"))
+	assert.Equal(t, "hertz/registry-etcd/hertz.test.demo/", codec.ServicePrefix("hertz.test.demo"))
+	assert.Equal(t, "env/prod/hertz.test.demo/127.0.0.1:8888", NewJSONCodec("env/prod/").Key("hertz.test.demo", "127.0.0.1:8888"))
+
+	// the layout of the etcd registry of kitex
+	codec = NewKitexCodec()
+	assert.Equal(t, "kitex/registry-etcd/kitex.test.demo/127.0.0.1:8888", codec.Key("kitex.test.demo", "127.0.0.1:8888"))
+	info := InstanceInfo{}
+	assert.Nil(t, codec.Unmarshal([]byte(`{"network":"tcp","address":"127.0.0.1:8888","weight":10,"tags":{"k":"v"}}`), &info))
+	assert.Equal(t, InstanceInfo{Network: "tcp", Address: "127.0.0.1:8888", Weight: 10, Tags: map[string]string{"k": "v"}}, info)
+	val, err := codec.Marshal(&info)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"network":"tcp","address":"127.0.0.1:8888","weight":10,"tags":{"k":"v"}}`, string(val))
+}
+
+func TestEtcdRegistryWithPrefix(t *testing.T) {
+	s, endpoint := setupEmbedEtcd(t)
+	defer teardownEmbedEtcd(s)
+
+	info := &registry.Info{
+		ServiceName: "registry-etcd-prefix",
+		Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8888"),
+		Weight:      10,
+	}
+	prod, err := NewEtcdRegistry([]string{endpoint}, WithPrefix("prod"))
+	assert.Nil(t, err)
+	assert.Nil(t, prod.Register(info))
+	defer prod.Deregister(info)
+
+	for _, tt := range []struct {
+		opts []Option
+		want int
+	}{
+		{opts: []Option{WithPrefix("prod")}, want: 1},
+		{opts: []Option{WithPrefix("test")}, want: 0},
+		{opts: nil, want: 0},
+	} {
+		rs, err := NewEtcdResolver([]string{endpoint}, tt.opts...)
+		assert.Nil(t, err)
+		result, err := rs.Resolve(context.TODO(), info.ServiceName)
+		assert.Nil(t, err)
+		assert.Len(t, result.Instances, tt.want)
+	}
+}
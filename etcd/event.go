@@ -0,0 +1,68 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+// EventType is the type of a lifecycle event of the registrations.
+type EventType int
+
+const (
+	// EventRegistered is reported when a service is registered by Register.
+	EventRegistered EventType = iota + 1
+	// EventLeaseLost is reported when the lease shared by the registrations is lost,
+	// so that the services are not discoverable until they are re-registered.
+	EventLeaseLost
+	// EventReregistered is reported when the services are registered again with a new lease.
+	EventReregistered
+	// EventGaveUp is reported when the registry gives up re-registering the services after
+	// the maximum number of attempts, they are registered again by the next Register only.
+	EventGaveUp
+	// EventConflict is reported with WithConflictDetection, when a service can not be registered again
+	// with a new lease, because another registry has registered its key meanwhile. The service is dropped
+	// from the registrations, while the others are registered again.
+	EventConflict
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventRegistered:
+		return "registered"
+	case EventLeaseLost:
+		return "lease lost"
+	case EventReregistered:
+		return "re-registered"
+	case EventGaveUp:
+		return "gave up"
+	case EventConflict:
+		return "conflict"
+	}
+	return "unknown"
+}
+
+// Event is a lifecycle event of the registrations.
+type Event struct {
+	Type EventType
+	// Key is the key of the service, for EventRegistered and EventConflict only,
+	// the other events are about all the services of the registry.
+	Key string
+	// Attempts is the number of the failed attempts to re-register the services.
+	Attempts uint
+	// Err is the last error for EventLeaseLost and EventGaveUp if any, or the *ConflictError for EventConflict.
+	Err error
+}
+
+// EventHandler is called with the lifecycle events of the registrations.
+// It is called synchronously and must not block.
+type EventHandler func(event Event)
@@ -20,12 +20,15 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hertz-contrib/registry/address"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -37,27 +40,70 @@ type option struct {
 	// etcd client config
 	etcdCfg  clientv3.Config
 	retryCfg *retryCfg
+	// onChange is called by the resolver when the instances of a service change
+	onChange ChangeNotifyFunc
+	// addressOpts decide the address registered by the registry
+	addressOpts []address.Option
+	// codec decides the keys and the values of the instances
+	codec Codec
+	// onEvent is called by the registry with the lifecycle events of the registrations
+	onEvent EventHandler
+	// conflictDetection makes the registry fail Register if the key is registered by another registry
+	conflictDetection bool
 }
 
 type retryCfg struct {
 	// The maximum number of call attempt times, including the initial call
 	maxAttemptTimes uint
-	// observeDelay is the delay time for checking the service status under normal conditions
+	// observeDelay is no longer used, the lease loss is found by its keepalive
 	observeDelay time.Duration
-	// retryDelay is the delay time for attempting to register the service after disconnecting
+	// retryDelay is the delay time for attempting to recover the lease after it is lost,
+	// which is the initial delay of the backoff
 	retryDelay time.Duration
+	// maxDelay is the maximum delay of the backoff, 0 means no limit
+	maxDelay time.Duration
+	// multiplier is the factor multiplying the delay after each failed attempt
+	multiplier float64
+	// jitter randomizes the delay by up to ±jitter of it
+	jitter float64
+}
+
+// delay returns the delay time before the next attempt after failedTimes failed attempts.
+func (r *retryCfg) delay(failedTimes uint) time.Duration {
+	d := float64(r.retryDelay)
+	for i := uint(1); i < failedTimes && r.multiplier > 1; i++ {
+		d *= r.multiplier
+		if r.maxDelay > 0 && d >= float64(r.maxDelay) {
+			break
+		}
+	}
+	if r.maxDelay > 0 && d > float64(r.maxDelay) {
+		d = float64(r.maxDelay)
+	}
+	if r.jitter > 0 {
+		d += d * r.jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
 }
 
 type Option func(o *option)
 
-// WithMaxAttemptTimes sets the maximum number of call attempt times, including the initial call
+// WithMaxAttemptTimes sets the maximum number of attempts to recover a lost lease, 0 means infinite attempts
 func WithMaxAttemptTimes(maxAttemptTimes uint) Option {
 	return func(o *option) {
 		o.retryCfg.maxAttemptTimes = maxAttemptTimes
 	}
 }
 
-// WithObserveDelay sets the delay time for checking the service status under normal conditions
+// WithNeverGiveUp makes the registry keep attempting to recover a lost lease until it succeeds,
+// which is the same as WithMaxAttemptTimes(0).
+func WithNeverGiveUp() Option {
+	return WithMaxAttemptTimes(0)
+}
+
+// WithObserveDelay sets the delay time for checking the service status under normal conditions.
+//
+// Deprecated: the registrations share a lease, whose loss is found by its keepalive instead of polling.
 func WithObserveDelay(observeDelay time.Duration) Option {
 	return func(o *option) {
 		o.retryCfg.observeDelay = observeDelay
@@ -71,27 +117,76 @@ func WithRetryDelay(t time.Duration) Option {
 	}
 }
 
-func (o *option) apply(opts ...Option) {
-	for _, opt := range opts {
-		opt(o)
+// WithBackoff sets the exponential backoff of the attempts to recover a lost lease. The delay starts
+// at initial, is multiplied by multiplier after each failed attempt up to max, and is randomized
+// by up to ±jitter of it, e.g. WithBackoff(time.Second, time.Minute, 2, 0.2).
+// Default: a fixed delay of 10 seconds without jitter
+func WithBackoff(initial, max time.Duration, multiplier, jitter float64) Option {
+	return func(o *option) {
+		o.retryCfg.retryDelay = initial
+		o.retryCfg.maxDelay = max
+		o.retryCfg.multiplier = multiplier
+		o.retryCfg.jitter = jitter
+	}
+}
+
+// WithEventHandler sets the callback used by the registry to report the lifecycle events of the registrations,
+// e.g. to fail the readiness probe of the service while it is not discoverable.
+func WithEventHandler(handler EventHandler) Option {
+	return func(o *option) {
+		o.onEvent = handler
+	}
+}
+
+// ChangeNotifyFunc is called with the description of a resolved service and its
+// latest instances whenever a key under the service prefix is put or deleted.
+type ChangeNotifyFunc func(desc string, result discovery.Result)
+
+// WithChangeNotify sets the callback used by the resolver to report instance changes,
+// which can be used to invalidate the resolver cache of hertz immediately.
+func WithChangeNotify(fn ChangeNotifyFunc) Option {
+	return func(o *option) {
+		o.onChange = fn
 	}
 }
 
-// instanceInfo used to stored service basic info in etcd.
-type instanceInfo struct {
-	Network string            `json:"network"`
-	Address string            `json:"address"`
-	Weight  int               `json:"weight"`
-	Tags    map[string]string `json:"tags"`
+// WithAddressOptions sets how the registry decides the address to register, see the address package.
+func WithAddressOptions(opts ...address.Option) Option {
+	return func(o *option) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
 }
 
-func serviceKeyPrefix(serviceName string) string {
-	return etcdPrefix + "/" + serviceName
+// WithPrefix sets the key prefix of the instances, which isolates the registries and the resolvers
+// sharing an etcd cluster, e.g. by environment. It uses the default codec with prefix.
+// Default: hertz/registry-etcd
+func WithPrefix(prefix string) Option {
+	return func(o *option) {
+		o.codec = NewJSONCodec(prefix)
+	}
 }
 
-// serviceKey generates the key used to stored in etcd.
-func serviceKey(serviceName, addr string) string {
-	return serviceKeyPrefix(serviceName) + "/" + addr
+// WithCodec sets the codec of the keys and the values of the instances,
+// e.g. NewKitexCodec to discover the services registered by kitex.
+func WithCodec(codec Codec) Option {
+	return func(o *option) {
+		o.codec = codec
+	}
+}
+
+// WithConflictDetection makes the registry fail Register with a *ConflictError, if the key of the service
+// is registered by another registry whose lease is alive, e.g. two processes advertise the same address,
+// and take the key over only if its lease has expired. The registry never deletes the keys of the others.
+func WithConflictDetection() Option {
+	return func(o *option) {
+		o.conflictDetection = true
+	}
+}
+
+func (o *option) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
 }
 
 // validateRegistryInfo validate the registry.Info
@@ -120,6 +215,7 @@ func getTTL() int64 {
 }
 
 // WithTLSOpt returns a option that authentication by tls/ssl.
+// It applies to the etcd clients created by both NewEtcdRegistry and NewEtcdResolver.
 func WithTLSOpt(certFile, keyFile, caFile string) Option {
 	return func(o *option) {
 		tlsCfg, err := newTLSConfig(certFile, keyFile, caFile, "")
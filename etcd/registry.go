@@ -16,97 +16,258 @@ package etcd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net"
-	"os"
+	"io"
 	"sync"
 	"time"
 
-	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hertz-contrib/registry/address"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
-var _ registry.Registry = (*etcdRegistry)(nil)
+var (
+	_ registry.Registry = (*etcdRegistry)(nil)
+	_ io.Closer         = (*etcdRegistry)(nil)
+)
+
+// ErrClosed is returned by the registry and the resolver after they are closed.
+var ErrClosed = errors.New("etcd registry or resolver is closed")
 
 const (
-	etcdPrefix          = "hertz/registry-etcd"
-	ttlKey              = "HERTZ_ETCD_REGISTRY_LEASE_TTL"
-	hertzIpToRegistry   = "HERTZ_IP_TO_REGISTRY"
-	hertzPortToRegistry = "HERTZ_PORT_TO_REGISTRY"
+	ttlKey = "HERTZ_ETCD_REGISTRY_LEASE_TTL"
+
+	statusDraining = "draining"
+
+	// maxTxnOps is the default maximum number of operations in an etcd transaction
+	maxTxnOps = 128
 )
 
 type etcdRegistry struct {
-	etcdClient  *clientv3.Client
+	etcdClient *clientv3.Client
+	// ownClient reports whether etcdClient is created by the registry, which closes it
+	ownClient   bool
 	retryConfig *retryCfg
+	addressOpts []address.Option
+	codec       Codec
+	onEvent     EventHandler
+	// conflictDetection makes the registry never overwrite or delete the registrations of the others
+	conflictDetection bool
 
 	leaseTTL int64
-	meta     *registerMeta
 	mu       sync.Mutex
-	stop     chan struct{}
-}
-
-type registerMeta struct {
+	// kvs holds the registered keys and values, which share the lease leaseID
+	kvs     map[string]string
 	leaseID clientv3.LeaseID
-	ctx     context.Context
-	cancel  context.CancelFunc
+	// cancel stops keeping leaseID alive
+	cancel context.CancelFunc
+	closed bool
+	// wg waits for the goroutines keeping the lease alive
+	wg sync.WaitGroup
 }
 
 // NewEtcdRegistry creates a etcd based registry.
+// The registry implements io.Closer, whose Close deregisters the services and closes the etcd client.
 func NewEtcdRegistry(endpoints []string, opts ...Option) (registry.Registry, error) {
 	cfg := newOptionForServer(endpoints, opts...)
 	etcdClient, err := clientv3.New(cfg.etcdCfg)
 	if err != nil {
 		return nil, err
 	}
+	r := newEtcdRegistry(etcdClient, cfg)
+	r.ownClient = true
+	return r, nil
+}
+
+// NewEtcdRegistryWithClient creates a etcd based registry with an existing etcd client, which can be shared
+// with a resolver and the other usages of the application. The options of the etcd client, e.g. WithTLSOpt,
+// are ignored. The registry implements io.Closer, whose Close deregisters the services but leaves the client open.
+func NewEtcdRegistryWithClient(etcdClient *clientv3.Client, opts ...Option) registry.Registry {
+	return newEtcdRegistry(etcdClient, newOptionForServer(etcdClient.Endpoints(), opts...))
+}
+
+func newEtcdRegistry(etcdClient *clientv3.Client, cfg *option) *etcdRegistry {
 	return &etcdRegistry{
-		etcdClient:  etcdClient,
-		leaseTTL:    getTTL(),
-		retryConfig: cfg.retryCfg,
-		stop:        make(chan struct{}, 1),
-	}, nil
+		etcdClient:        etcdClient,
+		leaseTTL:          getTTL(),
+		retryConfig:       cfg.retryCfg,
+		addressOpts:       cfg.addressOpts,
+		codec:             cfg.codec,
+		onEvent:           cfg.onEvent,
+		conflictDetection: cfg.conflictDetection,
+		kvs:               make(map[string]string),
+		leaseID:           clientv3.NoLease,
+	}
 }
 
 func (e *etcdRegistry) Register(info *registry.Info) error {
 	if err := validateRegistryInfo(info); err != nil {
 		return err
 	}
-	leaseID, err := e.grantLease()
+	addr, err := e.getAddressOfRegistration(info)
+	if err != nil {
+		return err
+	}
+	val, err := e.marshalInstance(info, addr, "")
 	if err != nil {
 		return err
 	}
+	key := e.codec.Key(info.ServiceName, addr)
 
-	if err := e.register(info, leaseID); err != nil {
+	e.mu.Lock()
+	conflicts, err := e.registerKey(key, val)
+	e.mu.Unlock()
+	e.notifyConflicts(conflicts)
+	if err != nil {
 		return err
 	}
-	meta := registerMeta{
-		leaseID: leaseID,
+	e.notify(Event{Type: EventRegistered, Key: key})
+	return nil
+}
+
+// registerKey puts key with the lease shared by the registrations, which must be called with e.mu held.
+// It returns the conflicts of the other keys put again with a new lease, which are dropped.
+func (e *etcdRegistry) registerKey(key, val string) ([]*ConflictError, error) {
+	if e.closed {
+		return nil, ErrClosed
+	}
+	if e.leaseID != clientv3.NoLease {
+		// registering the same key again replaces the previous value
+		if err := e.register(key, val, e.leaseID); err != nil {
+			return nil, err
+		}
+		e.kvs[key] = val
+		return nil, nil
+	}
+
+	// the first registration grants the lease shared by all the keys of the registry,
+	// and puts the keys of a lease that is lost, being recovered or could not be recovered too
+	leaseID, err := e.grantLease()
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string]string, len(e.kvs)+1)
+	for k, v := range e.kvs {
+		kvs[k] = v
 	}
-	meta.ctx, meta.cancel = context.WithCancel(context.Background())
-	if err := e.keepalive(meta); err != nil {
+	kvs[key] = val
+	conflicts, err := e.putAll(kvs, leaseID)
+	if err != nil {
+		e.revokeLease(leaseID)
+		return nil, err
+	}
+	for _, conflict := range conflicts {
+		if conflict.Key == key {
+			// the key to register conflicts, which fails Register
+			e.revokeLease(leaseID)
+			return nil, conflict
+		}
+	}
+	if e.cancel != nil {
+		// the new lease replaces the lease being recovered
+		e.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.kvs = kvs
+	e.dropConflicts(conflicts)
+	e.leaseID, e.cancel = leaseID, cancel
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.keepLease(ctx, leaseID)
+	}()
+	return conflicts, nil
+}
+
+func (e *etcdRegistry) Deregister(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	addr, err := e.getAddressOfRegistration(info)
+	if err != nil {
 		return err
 	}
+	key := e.codec.Key(info.ServiceName, addr)
+
 	e.mu.Lock()
-	e.meta = &meta
+	delete(e.kvs, key)
+	err = e.deregister(key, e.leaseID)
+	leaseID := clientv3.NoLease
+	if len(e.kvs) == 0 {
+		leaseID = e.stopLease()
+	}
 	e.mu.Unlock()
 
-	return nil
+	if leaseID != clientv3.NoLease {
+		e.revokeLease(leaseID)
+	}
+	return err
 }
 
-func (e *etcdRegistry) Deregister(info *registry.Info) error {
+// Drain marks a registered instance as draining, so that it is no longer resolved while it is still registered.
+func (e *etcdRegistry) Drain(info *registry.Info) error {
 	if err := validateRegistryInfo(info); err != nil {
 		return err
 	}
-	if err := e.deregister(info); err != nil {
+	addr, err := e.getAddressOfRegistration(info)
+	if err != nil {
+		return err
+	}
+	val, err := e.marshalInstance(info, addr, statusDraining)
+	if err != nil {
 		return err
 	}
+	key := e.codec.Key(info.ServiceName, addr)
+
 	e.mu.Lock()
-	e.meta.cancel()
+	defer e.mu.Unlock()
+	if _, ok := e.kvs[key]; !ok {
+		return fmt.Errorf("instance{%s} has not registered", key)
+	}
+	// the value is put again once the lease is recovered, which must not make the instance serving again
+	e.kvs[key] = val
+	if e.leaseID == clientv3.NoLease {
+		return fmt.Errorf("instance{%s} has lost its lease", key)
+	}
+	return e.register(key, val, e.leaseID)
+}
+
+// Close revokes the lease of the registry, which deregisters all the services, and stops keeping it alive.
+// The etcd client is closed too, unless it is passed to NewEtcdRegistryWithClient.
+func (e *etcdRegistry) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	leaseID := e.stopLease()
+	e.kvs = make(map[string]string)
 	e.mu.Unlock()
+
+	if leaseID != clientv3.NoLease {
+		e.revokeLease(leaseID)
+	}
+	e.wg.Wait()
+	if e.ownClient {
+		return e.etcdClient.Close()
+	}
 	return nil
 }
 
+// stopLease stops keeping the lease alive or recovering it, which must be called with e.mu held.
+// It returns the lease to revoke, if any, which is revoked by the caller after releasing e.mu.
+func (e *etcdRegistry) stopLease() clientv3.LeaseID {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	leaseID := e.leaseID
+	e.leaseID, e.cancel = clientv3.NoLease, nil
+	return leaseID
+}
+
 func (e *etcdRegistry) grantLease() (clientv3.LeaseID, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
@@ -117,190 +278,197 @@ func (e *etcdRegistry) grantLease() (clientv3.LeaseID, error) {
 	return resp.ID, nil
 }
 
-func (e *etcdRegistry) register(info *registry.Info, leaseID clientv3.LeaseID) error {
-	addr, err := e.getAddressOfRegistration(info)
-	if err != nil {
-		return err
-	}
-	val, err := sonic.Marshal(&instanceInfo{
-		Network: info.Addr.Network(),
-		Address: addr,
-		Weight:  info.Weight,
-		Tags:    info.Tags,
-	})
-	if err != nil {
-		return err
-	}
+func (e *etcdRegistry) revokeLease(leaseID clientv3.LeaseID) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
-	_, err = e.etcdClient.Put(ctx, serviceKey(info.ServiceName, addr), string(val), clientv3.WithLease(leaseID))
-	if err != nil {
-		return err
+	if _, err := e.etcdClient.Revoke(ctx, leaseID); err != nil {
+		hlog.Warnf("HERTZ: Revoke lease %x of etcd registry failed, err: %v", leaseID, err)
 	}
-
-	// retry start
-	go func(key, val string) {
-		e.keepRegister(key, val, e.retryConfig)
-	}(serviceKey(info.ServiceName, addr), string(val))
-
-	return nil
 }
 
-func (e *etcdRegistry) deregister(info *registry.Info) error {
+func (e *etcdRegistry) register(key, val string, leaseID clientv3.LeaseID) error {
+	if e.conflictDetection {
+		return e.putExclusive(key, val, leaseID)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
-	addr, err := e.getAddressOfRegistration(info)
-	if err != nil {
-		return err
+	_, err := e.etcdClient.Put(ctx, key, val, clientv3.WithLease(leaseID))
+	return err
+}
+
+// putAll puts kvs with leaseID in one transaction, which is split into batches of
+// maxTxnOps puts only if there are more keys than etcd allows in a transaction by default.
+// With conflict detection, the keys registered by the others are skipped and returned as conflicts.
+func (e *etcdRegistry) putAll(kvs map[string]string, leaseID clientv3.LeaseID) ([]*ConflictError, error) {
+	if e.conflictDetection {
+		return e.putAllExclusive(kvs, leaseID)
 	}
-	_, err = e.etcdClient.Delete(ctx, serviceKey(info.ServiceName, addr))
-	if err != nil {
-		return err
+	ops := make([]clientv3.Op, 0, len(kvs))
+	for key, val := range kvs {
+		ops = append(ops, clientv3.OpPut(key, val, clientv3.WithLease(leaseID)))
 	}
-	e.stop <- struct{}{}
-	return nil
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		_, err := e.etcdClient.Txn(ctx).Then(ops[:n]...).Commit()
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		ops = ops[n:]
+	}
+	return nil, nil
 }
 
-// keepalive keep the lease alive
-func (e *etcdRegistry) keepalive(meta registerMeta) error {
-	keepAlive, err := e.etcdClient.KeepAlive(meta.ctx, meta.leaseID)
-	if err != nil {
-		return err
+func (e *etcdRegistry) deregister(key string, leaseID clientv3.LeaseID) error {
+	if e.conflictDetection {
+		return e.deleteExclusive(key, leaseID)
 	}
-	go func() {
-		// eat keepAlive channel to keep related lease alive.
-		hlog.Infof("HERTZ: Start keepalive lease %x for etcd registry", meta.leaseID)
-		for range keepAlive {
-			select {
-			case <-meta.ctx.Done():
-				hlog.Infof("HERTZ: Stop keepalive lease %x for etcd registry", meta.leaseID)
-				return
-			default:
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_, err := e.etcdClient.Delete(ctx, key)
+	return err
+}
+
+// keepLease keeps the lease alive until ctx is done. The keepalive channel is closed once
+// the lease is lost, e.g. expired while etcd is unreachable, then the lease is recovered.
+func (e *etcdRegistry) keepLease(ctx context.Context, leaseID clientv3.LeaseID) {
+	for {
+		keepAlive, err := e.etcdClient.KeepAlive(ctx, leaseID)
+		if err == nil {
+			hlog.Infof("HERTZ: Start keepalive lease %x for etcd registry", leaseID)
+			// eat keepAlive channel to keep related lease alive.
+			for range keepAlive {
 			}
 		}
-	}()
-	return nil
+		if ctx.Err() != nil {
+			hlog.Infof("HERTZ: Stop keepalive lease %x for etcd registry", leaseID)
+			return
+		}
+		hlog.Warnf("HERTZ: Lease %x of etcd registry is lost, err: %v", leaseID, err)
+		e.mu.Lock()
+		if e.leaseID == leaseID {
+			// Register grants a new lease rather than putting a key with the lost one
+			e.leaseID = clientv3.NoLease
+		}
+		e.mu.Unlock()
+		e.notify(Event{Type: EventLeaseLost, Err: err})
+
+		var ok bool
+		if leaseID, ok = e.recoverLease(ctx); !ok {
+			return
+		}
+	}
 }
 
-// keepRegister keep register service by retryConfig
-func (e *etcdRegistry) keepRegister(key, val string, retryConfig *retryCfg) {
+// recoverLease grants a new lease and puts every registered key with it, retrying with the backoff of retryConfig.
+// If it gives up, the keys are put again by the next Register.
+func (e *etcdRegistry) recoverLease(ctx context.Context) (clientv3.LeaseID, bool) {
 	var (
 		failedTimes uint
-		resp        *clientv3.GetResponse
-		err         error
-		ctx         context.Context
-		cancel      context.CancelFunc
-		wg          sync.WaitGroup
+		lastErr     error
 	)
+	// if maxAttemptTimes is 0, keep recovering forever
+	for e.retryConfig.maxAttemptTimes == 0 || failedTimes < e.retryConfig.maxAttemptTimes {
+		leaseID, conflicts, err := e.renewLease(ctx)
+		e.notifyConflicts(conflicts)
+		if err == nil {
+			hlog.Infof("HERTZ: Recover the registrations of etcd registry with lease %x", leaseID)
+			e.notify(Event{Type: EventReregistered, Attempts: failedTimes})
+			return leaseID, true
+		}
+		if ctx.Err() != nil {
+			return clientv3.NoLease, false
+		}
+		failedTimes++
+		lastErr = err
+		delay := e.retryConfig.delay(failedTimes)
+		hlog.Warnf("HERTZ: Recover the lease of etcd registry failed, retry in %v, err: %v", delay, err)
 
-	delay := retryConfig.observeDelay
-	// if maxAttemptTimes is 0, keep register forever
-	for retryConfig.maxAttemptTimes == 0 || failedTimes < retryConfig.maxAttemptTimes {
 		select {
-		case _, ok := <-e.stop:
-			if !ok {
-				close(e.stop)
-			}
-			hlog.Infof("stop keep register service %s", key)
-			return
+		case <-ctx.Done():
+			return clientv3.NoLease, false
 		case <-time.After(delay):
 		}
+	}
+	hlog.Errorf("HERTZ: Recover the lease of etcd registry failed times: %d", failedTimes)
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			ctx, cancel = context.WithTimeout(context.Background(), time.Second*3)
-			defer cancel()
-			resp, err = e.etcdClient.Get(ctx, key)
-		}()
-		wg.Wait()
-
-		if err != nil {
-			hlog.Warnf("keep register get %s failed with err: %v", key, err)
-			delay = retryConfig.retryDelay
-			failedTimes++
-			continue
-		}
-
-		if len(resp.Kvs) == 0 {
-			hlog.Infof("keep register service %s", key)
-			delay = retryConfig.retryDelay
-			leaseID, err := e.grantLease()
-			if err != nil {
-				hlog.Warnf("keep register grant lease %s failed with err: %v", key, err)
-				failedTimes++
-				continue
-			}
-
-			_, err = e.etcdClient.Put(ctx, key, val, clientv3.WithLease(leaseID))
-			if err != nil {
-				hlog.Warnf("keep register put %s failed with err: %v", key, err)
-				failedTimes++
-				continue
-			}
-
-			meta := registerMeta{
-				leaseID: leaseID,
-			}
-			meta.ctx, meta.cancel = context.WithCancel(context.Background())
-			if err := e.keepalive(meta); err != nil {
-				hlog.Warnf("keep register keepalive %s failed with err: %v", key, err)
-				failedTimes++
-				continue
-			}
-			e.meta.cancel()
-			e.meta = &meta
-			delay = retryConfig.observeDelay
-		}
-		failedTimes = 0
+	e.mu.Lock()
+	gaveUp := ctx.Err() == nil
+	if gaveUp {
+		e.cancel()
+		e.leaseID, e.cancel = clientv3.NoLease, nil
+	}
+	e.mu.Unlock()
+	if gaveUp {
+		e.notify(Event{Type: EventGaveUp, Attempts: failedTimes, Err: lastErr})
 	}
-	hlog.Errorf("keep register service %s failed times:%d", key, failedTimes)
+	return clientv3.NoLease, false
 }
 
-// getAddressOfRegistration returns the address of the service registration.
-func (e *etcdRegistry) getAddressOfRegistration(info *registry.Info) (string, error) {
-	host, port, err := net.SplitHostPort(info.Addr.String())
+// renewLease grants a new lease and puts every registered key with it.
+// The keys registered by the others meanwhile are dropped and returned as conflicts.
+func (e *etcdRegistry) renewLease(ctx context.Context) (clientv3.LeaseID, []*ConflictError, error) {
+	leaseID, err := e.grantLease()
 	if err != nil {
-		return "", err
+		return clientv3.NoLease, nil, err
 	}
 
-	// if host is empty or "::", use local ipv4 address as host
-	if host == "" || host == "::" {
-		host, err = getLocalIPv4Host()
-		if err != nil {
-			return "", fmt.Errorf("parse registry info addr error: %w", err)
-		}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the last key may be deregistered meanwhile
+	if err := ctx.Err(); err != nil {
+		e.revokeLease(leaseID)
+		return clientv3.NoLease, nil, err
 	}
-
-	// if env HERTZ_IP_TO_REGISTRY is set, use it as host
-	if ipToRegistry, exists := os.LookupEnv(hertzIpToRegistry); exists && ipToRegistry != "" {
-		host = ipToRegistry
+	conflicts, err := e.putAll(e.kvs, leaseID)
+	if err != nil {
+		e.revokeLease(leaseID)
+		return clientv3.NoLease, nil, err
 	}
-
-	// if env HERTZ_PORT_TO_REGISTRY is set, use it as port
-	if portToRegistry, exists := os.LookupEnv(hertzPortToRegistry); exists && portToRegistry != "" {
-		port = portToRegistry
+	e.dropConflicts(conflicts)
+	if len(e.kvs) == 0 {
+		// every key is registered by the others, nothing is left to keep alive
+		e.revokeLease(leaseID)
+		e.cancel()
+		e.leaseID, e.cancel = clientv3.NoLease, nil
+		return clientv3.NoLease, conflicts, ctx.Err()
 	}
+	e.leaseID = leaseID
+	return leaseID, conflicts, nil
+}
 
-	return net.JoinHostPort(host, port), nil
+// notify reports event to the event handler, if any.
+func (e *etcdRegistry) notify(event Event) {
+	if e.onEvent != nil {
+		e.onEvent(event)
+	}
 }
 
-func getLocalIPv4Host() (string, error) {
-	addr, err := net.InterfaceAddrs()
+func (e *etcdRegistry) marshalInstance(info *registry.Info, addr, status string) (string, error) {
+	val, err := e.codec.Marshal(&InstanceInfo{
+		Network: info.Addr.Network(),
+		Address: addr,
+		Weight:  info.Weight,
+		Tags:    info.Tags,
+		Status:  status,
+	})
 	if err != nil {
 		return "", err
 	}
+	return string(val), nil
+}
 
-	for _, addr := range addr {
-		ipNet, isIpNet := addr.(*net.IPNet)
-		if isIpNet && !ipNet.IP.IsLoopback() {
-			ipv4 := ipNet.IP.To4()
-			if ipv4 != nil {
-				return ipv4.String(), nil
-			}
-		}
+// getAddressOfRegistration returns the address of the service registration.
+func (e *etcdRegistry) getAddressOfRegistration(info *registry.Info) (string, error) {
+	addr, err := address.Advertise(info.Addr, e.addressOpts...)
+	if err != nil {
+		return "", fmt.Errorf("parse registry info addr error: %w", err)
 	}
-	return "", fmt.Errorf("not found ipv4 address")
+	return addr, nil
 }
 
 func newOptionForServer(endpoints []string, opts ...Option) *option {
@@ -308,10 +476,12 @@ func newOptionForServer(endpoints []string, opts ...Option) *option {
 		etcdCfg: clientv3.Config{
 			Endpoints: endpoints,
 		},
+		codec: NewJSONCodec(DefaultPrefix),
 		retryCfg: &retryCfg{
 			maxAttemptTimes: 5,
 			observeDelay:    30 * time.Second,
 			retryDelay:      10 * time.Second,
+			multiplier:      1,
 		},
 	}
 	cfg.apply(opts...)
@@ -16,63 +16,122 @@ package etcd
 
 import (
 	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
 
-	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/client/discovery"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
-var _ discovery.Resolver = (*etcdResolver)(nil)
+const defaultRewatchDelay = time.Second
+
+var (
+	_ discovery.Resolver = (*etcdResolver)(nil)
+	_ io.Closer          = (*etcdResolver)(nil)
+)
 
 type etcdResolver struct {
 	etcdClient *clientv3.Client
+	// ownClient reports whether etcdClient is created by the resolver, which closes it
+	ownClient bool
+	onChange  ChangeNotifyFunc
+	codec     Codec
+
+	mu       sync.Mutex
+	watchers map[string]*serviceWatcher
+	closed   bool
+	// wg waits for the goroutines watching the services
+	wg sync.WaitGroup
+}
+
+// serviceWatcher keeps a local snapshot of the instances under one service key prefix,
+// maintained by an etcd watch.
+type serviceWatcher struct {
+	desc   string
+	prefix string
+	codec  Codec
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.RWMutex
+	revision  int64
+	instances map[string]discovery.Instance
 }
 
 // NewEtcdResolver creates a etcd based resolver.
+// The resolver implements io.Closer, whose Close stops watching the services and closes the etcd client.
 func NewEtcdResolver(endpoints []string, opts ...Option) (discovery.Resolver, error) {
-	cfg := &option{
-		etcdCfg: clientv3.Config{
-			Endpoints: endpoints,
-		},
-	}
-	cfg.apply(opts...)
+	cfg := newOptionForResolver(endpoints, opts...)
 	etcdClient, err := clientv3.New(cfg.etcdCfg)
 	if err != nil {
 		return nil, err
 	}
+	r := newEtcdResolver(etcdClient, cfg)
+	r.ownClient = true
+	return r, nil
+}
+
+// NewEtcdResolverWithClient creates a etcd based resolver with an existing etcd client, which can be shared
+// with a registry and the other usages of the application. The options of the etcd client, e.g. WithTLSOpt,
+// are ignored. The resolver implements io.Closer, whose Close stops watching the services but leaves the client open.
+func NewEtcdResolverWithClient(etcdClient *clientv3.Client, opts ...Option) discovery.Resolver {
+	return newEtcdResolver(etcdClient, newOptionForResolver(etcdClient.Endpoints(), opts...))
+}
+
+func newEtcdResolver(etcdClient *clientv3.Client, cfg *option) *etcdResolver {
 	return &etcdResolver{
 		etcdClient: etcdClient,
-	}, nil
+		onChange:   cfg.onChange,
+		codec:      cfg.codec,
+		watchers:   make(map[string]*serviceWatcher),
+	}
+}
+
+func newOptionForResolver(endpoints []string, opts ...Option) *option {
+	cfg := &option{
+		etcdCfg: clientv3.Config{
+			Endpoints: endpoints,
+		},
+		codec: NewJSONCodec(DefaultPrefix),
+	}
+	cfg.apply(opts...)
+	return cfg
 }
 
 // Resolve implements the Resolver interface.
+// The first call for a desc loads the instances from etcd and starts a watch on its prefix,
+// later calls are served from the local snapshot.
 func (e *etcdResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
-	path := desc + "/"
-	prefix := serviceKeyPrefix(path)
-	resp, err := e.etcdClient.Get(ctx, prefix, clientv3.WithPrefix())
+	w, err := e.getWatcher(ctx, desc)
 	if err != nil {
 		return discovery.Result{}, err
 	}
-	var eps []discovery.Instance
-	for _, kv := range resp.Kvs {
-		var info instanceInfo
-		err := sonic.Unmarshal(kv.Value, &info)
-		if err != nil {
-			hlog.Warnf("HERTZ: fail to unmarshal with err: %v, ignore key: %v", err, string(kv.Key))
-			continue
-		}
-		weight := info.Weight
-		if weight <= 0 {
-			weight = registry.DefaultWeight
-		}
-		eps = append(eps, discovery.NewInstance(info.Network, info.Address, weight, info.Tags))
+	return w.result(), nil
+}
+
+// Close stops watching the services. The etcd client is closed too, unless it is passed to NewEtcdResolverWithClient.
+func (e *etcdResolver) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
 	}
-	return discovery.Result{
-		CacheKey:  desc,
-		Instances: eps,
-	}, nil
+	e.closed = true
+	for _, w := range e.watchers {
+		w.cancel()
+	}
+	e.watchers = make(map[string]*serviceWatcher)
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	if e.ownClient {
+		return e.etcdClient.Close()
+	}
+	return nil
 }
 
 func (e *etcdResolver) Name() string {
@@ -82,3 +141,189 @@ func (e *etcdResolver) Name() string {
 func (e *etcdResolver) Target(ctx context.Context, target *discovery.TargetInfo) string {
 	return target.Host
 }
+
+// getWatcher returns the watcher of desc, creating and starting it if needed.
+func (e *etcdResolver) getWatcher(ctx context.Context, desc string) (*serviceWatcher, error) {
+	e.mu.Lock()
+	w, ok := e.watchers[desc]
+	closed := e.closed
+	e.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	if ok {
+		return w, nil
+	}
+
+	w = &serviceWatcher{
+		desc:   desc,
+		prefix: e.codec.ServicePrefix(desc),
+		codec:  e.codec,
+	}
+	if err := e.load(ctx, w); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil, ErrClosed
+	}
+	// another goroutine may have started the watcher while we were loading
+	if exist, ok := e.watchers[desc]; ok {
+		return exist, nil
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	e.watchers[desc] = w
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.watch(w)
+	}()
+	return w, nil
+}
+
+// load replaces the snapshot of w with the current instances in etcd.
+func (e *etcdResolver) load(ctx context.Context, w *serviceWatcher) error {
+	resp, err := e.etcdClient.Get(ctx, w.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	instances := make(map[string]discovery.Instance, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if ins, ok := parseInstance(w.codec, kv.Key, kv.Value); ok && ins != nil {
+			instances[string(kv.Key)] = ins
+		}
+	}
+	w.mu.Lock()
+	w.instances = instances
+	w.revision = resp.Header.Revision
+	w.mu.Unlock()
+	return nil
+}
+
+// watch keeps the snapshot of w up to date until w.ctx is done.
+// The watch is resumed from the last seen revision when the stream breaks,
+// and the snapshot is reloaded when that revision has been compacted.
+func (e *etcdResolver) watch(w *serviceWatcher) {
+	hlog.Infof("HERTZ: Start watching %s for etcd resolver", w.prefix)
+	for {
+		w.mu.RLock()
+		rev := w.revision + 1
+		w.mu.RUnlock()
+
+		wch := e.etcdClient.Watch(clientv3.WithRequireLeader(w.ctx), w.prefix,
+			clientv3.WithPrefix(), clientv3.WithRev(rev))
+		compacted := false
+		for resp := range wch {
+			if resp.CompactRevision != 0 {
+				hlog.Warnf("HERTZ: watch %s compacted at revision %d, reload instances", w.prefix, resp.CompactRevision)
+				compacted = true
+				break
+			}
+			if err := resp.Err(); err != nil {
+				hlog.Warnf("HERTZ: watch %s failed with err: %v", w.prefix, err)
+				break
+			}
+			if w.apply(resp.Events, resp.Header.Revision) {
+				e.notify(w)
+			}
+		}
+
+		select {
+		case <-w.ctx.Done():
+			hlog.Infof("HERTZ: Stop watching %s for etcd resolver", w.prefix)
+			return
+		case <-time.After(defaultRewatchDelay):
+		}
+
+		if compacted {
+			ctx, cancel := context.WithTimeout(w.ctx, time.Second*3)
+			err := e.load(ctx, w)
+			cancel()
+			if err != nil {
+				hlog.Warnf("HERTZ: reload %s failed with err: %v", w.prefix, err)
+				continue
+			}
+			e.notify(w)
+		}
+	}
+}
+
+func (e *etcdResolver) notify(w *serviceWatcher) {
+	if e.onChange != nil {
+		e.onChange(w.desc, w.result())
+	}
+}
+
+// apply updates the snapshot with the watch events and reports whether it has changed.
+func (w *serviceWatcher) apply(events []*clientv3.Event, revision int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := false
+	for _, ev := range events {
+		key := string(ev.Kv.Key)
+		switch ev.Type {
+		case clientv3.EventTypePut:
+			ins, ok := parseInstance(w.codec, ev.Kv.Key, ev.Kv.Value)
+			if !ok {
+				continue
+			}
+			if ins != nil {
+				w.instances[key] = ins
+				changed = true
+			} else if _, ok := w.instances[key]; ok {
+				// the instance is drained
+				delete(w.instances, key)
+				changed = true
+			}
+		case clientv3.EventTypeDelete:
+			if _, ok := w.instances[key]; ok {
+				delete(w.instances, key)
+				changed = true
+			}
+		}
+	}
+	if revision > w.revision {
+		w.revision = revision
+	}
+	return changed
+}
+
+func (w *serviceWatcher) result() discovery.Result {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	keys := make([]string, 0, len(w.instances))
+	for key := range w.instances {
+		keys = append(keys, key)
+	}
+	// keep the order of a prefix Get, which is sorted by key
+	sort.Strings(keys)
+	var eps []discovery.Instance
+	for _, key := range keys {
+		eps = append(eps, w.instances[key])
+	}
+	return discovery.Result{
+		CacheKey:  w.desc,
+		Instances: eps,
+	}
+}
+
+// parseInstance parses the instance stored at key, which is nil if the instance is drained.
+// It reports false if the value is invalid.
+func parseInstance(codec Codec, key, value []byte) (discovery.Instance, bool) {
+	var info InstanceInfo
+	err := codec.Unmarshal(value, &info)
+	if err != nil {
+		hlog.Warnf("HERTZ: fail to unmarshal with err: %v, ignore key: %v", err, string(key))
+		return nil, false
+	}
+	if info.Status == statusDraining {
+		return nil, true
+	}
+	weight := info.Weight
+	if weight <= 0 {
+		weight = registry.DefaultWeight
+	}
+	return discovery.NewInstance(info.Network, info.Address, weight, info.Tags), true
+}
@@ -5,6 +5,7 @@ go 1.16
 require (
 	github.com/bytedance/sonic v1.12.7
 	github.com/cloudwego/hertz v0.9.6
+	github.com/hertz-contrib/registry/address v0.1.0
 	github.com/hudl/fargo v1.4.0
 	github.com/stretchr/testify v1.10.0
 )
@@ -19,14 +19,13 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"strconv"
 	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
-	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/hudl/fargo"
 )
 
@@ -60,10 +59,33 @@ type eurekaRegistry struct {
 	lock             *sync.RWMutex
 	registryIns      map[string]*eurekaHeartbeat
 	heatBeatInterval time.Duration
+	opts             options
+}
+
+type options struct {
+	addressOpts []address.Option
+}
+
+// Option is the option of eureka registry.
+type Option func(o *options)
+
+// WithAddressOptions sets how the registry decides the address to register, see the address package.
+func WithAddressOptions(opts ...address.Option) Option {
+	return func(o *options) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
 // NewEurekaRegistry creates a eureka registry.
-func NewEurekaRegistry(servers []string, heatBeatInterval time.Duration) *eurekaRegistry {
+func NewEurekaRegistry(servers []string, heatBeatInterval time.Duration, opts ...Option) *eurekaRegistry {
 	conn := fargo.NewConn(servers...)
 
 	return &eurekaRegistry{
@@ -71,11 +93,12 @@ func NewEurekaRegistry(servers []string, heatBeatInterval time.Duration) *eureka
 		registryIns:      make(map[string]*eurekaHeartbeat),
 		lock:             &sync.RWMutex{},
 		heatBeatInterval: heatBeatInterval,
+		opts:             newOptions(opts),
 	}
 }
 
 // NewEurekaRegistryFromConfig creates a eureka registry.
-func NewEurekaRegistryFromConfig(config fargo.Config, heatBeatInterval time.Duration) *eurekaRegistry {
+func NewEurekaRegistryFromConfig(config fargo.Config, heatBeatInterval time.Duration, opts ...Option) *eurekaRegistry {
 	conn := fargo.NewConnFromConfig(config)
 
 	return &eurekaRegistry{
@@ -83,16 +106,18 @@ func NewEurekaRegistryFromConfig(config fargo.Config, heatBeatInterval time.Dura
 		registryIns:      make(map[string]*eurekaHeartbeat),
 		lock:             &sync.RWMutex{},
 		heatBeatInterval: heatBeatInterval,
+		opts:             newOptions(opts),
 	}
 }
 
 // NewEurekaRegistryFromConn creates a eureka registry.
-func NewEurekaRegistryFromConn(conn fargo.EurekaConnection, heatBeatInterval time.Duration) *eurekaRegistry {
+func NewEurekaRegistryFromConn(conn fargo.EurekaConnection, heatBeatInterval time.Duration, opts ...Option) *eurekaRegistry {
 	return &eurekaRegistry{
 		eurekaConn:       &conn,
 		registryIns:      make(map[string]*eurekaHeartbeat),
 		lock:             &sync.RWMutex{},
 		heatBeatInterval: heatBeatInterval,
+		opts:             newOptions(opts),
 	}
 }
 
@@ -124,6 +149,25 @@ func (e *eurekaRegistry) Deregister(info *registry.Info) error {
 	return nil
 }
 
+// Drain marks a registered server as OUT_OF_SERVICE, so that it is no longer resolved while it is still registered.
+func (e *eurekaRegistry) Drain(info *registry.Info) error {
+	instance, err := e.eurekaInstance(info)
+	if err != nil {
+		return err
+	}
+
+	instanceKey := fmt.Sprintf("%s:%s", info.ServiceName, info.Addr.String())
+
+	e.lock.RLock()
+	_, ok := e.registryIns[instanceKey]
+	e.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("instance{%s} has not registered", instanceKey)
+	}
+
+	return e.eurekaConn.UpdateInstanceStatus(instance, fargo.OUTOFSERVICE)
+}
+
 // Register a server with given registry info.
 func (e *eurekaRegistry) Register(info *registry.Info) error {
 	instance, err := e.eurekaInstance(info)
@@ -168,29 +212,18 @@ func (e *eurekaRegistry) eurekaInstance(info *registry.Info) (*fargo.Instance, e
 		return nil, ErrEmptyServiceName
 	}
 
-	host, portStr, err := net.SplitHostPort(info.Addr.String())
+	_, portStr, err := net.SplitHostPort(info.Addr.String())
 	if err != nil {
 		return nil, err
 	}
-	if portStr == "" {
-		return nil, fmt.Errorf("registry info addr missing port")
-	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
-		if host == utils.UNKNOWN_IP_ADDR {
-			return nil, fmt.Errorf("get local ip error")
-		}
+	if portStr == "" || portStr == "0" {
+		return nil, ErrMissingPort
 	}
-
-	port, err := strconv.Atoi(portStr)
+	host, port, err := address.AdvertiseHostPort(info.Addr, e.opts.addressOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	if port <= 0 {
-		return nil, ErrMissingPort
-	}
-
 	if info.Weight == 0 {
 		info.Weight = registry.DefaultWeight
 	}
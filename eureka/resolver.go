@@ -86,6 +86,10 @@ func (r *eurekaResolver) Name() string {
 func (r *eurekaResolver) getInstances(instances []*fargo.Instance) ([]discovery.Instance, error) {
 	res := make([]discovery.Instance, 0, len(instances))
 	for _, instance := range instances {
+		// skip the instances which are drained or not ready yet
+		if instance.Status != fargo.UP {
+			continue
+		}
 		dInstance, err := r.getInstance(instance)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,169 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultHost = "127.0.0.1"
+	lockSuffix  = ".lock"
+)
+
+// instanceInfo is an instance in the file, the same as the value of an instance in etcd.
+type instanceInfo struct {
+	Network string            `json:"network" yaml:"network"`
+	Address string            `json:"address" yaml:"address"`
+	Weight  int               `json:"weight" yaml:"weight"`
+	Tags    map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// services is the content of the file, the instances by service name.
+type services map[string][]instanceInfo
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func decode(path string, data []byte) (services, error) {
+	s := make(services)
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return s, nil
+	}
+	var err error
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, &s)
+	} else {
+		err = json.Unmarshal(data, &s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode %s failed: %w", path, err)
+	}
+	return s, nil
+}
+
+func encode(path string, s services) ([]byte, error) {
+	if isYAML(path) {
+		return yaml.Marshal(s)
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// readFile returns the content of the file, a missing file is empty.
+func readFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// writeFile replaces the file with data atomically, so that readers never see a partial file.
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// update runs fn on the content of the file and writes the result back,
+// holding the lock of the file so that several processes can share it.
+func update(path string, fn func(s services)) error {
+	unlock, err := lockFile(path + lockSuffix)
+	if err != nil {
+		return fmt.Errorf("lock %s failed: %w", path, err)
+	}
+	defer unlock()
+
+	data, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	s, err := decode(path, data)
+	if err != nil {
+		return err
+	}
+	fn(s)
+	for name, instances := range s {
+		if len(instances) == 0 {
+			delete(s, name)
+			continue
+		}
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i].Address < instances[j].Address
+		})
+	}
+	data, err = encode(path, s)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+func validateRegistryInfo(info *registry.Info) error {
+	if info == nil {
+		return fmt.Errorf("registry.Info can not be empty")
+	}
+	if info.ServiceName == "" {
+		return fmt.Errorf("registry.Info ServiceName can not be empty")
+	}
+	if info.Addr == nil {
+		return fmt.Errorf("registry.Info Addr can not be empty")
+	}
+	return nil
+}
+
+// getAddress returns the address of info, an unspecified host is replaced by
+// the loopback address since the file is only shared by local processes.
+func getAddress(info *registry.Info) (string, error) {
+	host, port, err := net.SplitHostPort(info.Addr.String())
+	if err != nil {
+		return "", fmt.Errorf("parse registry info addr %s failed: %w", info.Addr.String(), err)
+	}
+	if port == "" || port == "0" {
+		return "", fmt.Errorf("registry info addr %s has no port", info.Addr.String())
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = defaultHost
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resolveAddrs(t *testing.T, r discovery.Resolver, service string) []string {
+	res, err := r.Resolve(context.Background(), service)
+	require.Nil(t, err)
+	var addrs []string
+	for _, ins := range res.Instances {
+		addrs = append(addrs, ins.Address().String())
+	}
+	return addrs
+}
+
+func TestRegistryAndResolver(t *testing.T) {
+	for _, name := range []string{"registry.json", "registry.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			r := NewFileRegistry(path)
+			info1 := &registry.Info{
+				ServiceName: "hertz.test.demo",
+				Addr:        utils.NewNetAddr("tcp", "0.0.0.0:8888"),
+				Weight:      10,
+				Tags:        map[string]string{"key1": "value1"},
+			}
+			info2 := &registry.Info{
+				ServiceName: "hertz.test.demo",
+				Addr:        utils.NewNetAddr("tcp", "127.0.0.1:8889"),
+				Weight:      20,
+			}
+			info3 := &registry.Info{
+				ServiceName: "hertz.test.other",
+				Addr:        utils.NewNetAddr("tcp", "127.0.0.1:9999"),
+				Weight:      10,
+			}
+			for _, info := range []*registry.Info{info1, info2, info3} {
+				require.Nil(t, r.Register(info))
+			}
+			// registering again replaces the instance
+			require.Nil(t, r.Register(info1))
+
+			res := NewFileResolver(path, WithPollInterval(10*time.Millisecond))
+			assert.Equal(t, "hertz.test.demo", res.Target(context.Background(), &discovery.TargetInfo{Host: "hertz.test.demo"}))
+			result, err := res.Resolve(context.Background(), "hertz.test.demo")
+			require.Nil(t, err)
+			assert.Equal(t, "hertz.test.demo", result.CacheKey)
+			require.Len(t, result.Instances, 2)
+			assert.Equal(t, "127.0.0.1:8888", result.Instances[0].Address().String())
+			value, ok := result.Instances[0].Tag("key1")
+			assert.True(t, ok)
+			assert.Equal(t, "value1", value)
+			assert.Equal(t, "127.0.0.1:8889", result.Instances[1].Address().String())
+			assert.Equal(t, 20, result.Instances[1].Weight())
+			assert.Equal(t, []string{"127.0.0.1:9999"}, resolveAddrs(t, res, "hertz.test.other"))
+
+			require.Nil(t, r.Deregister(info1))
+			require.Nil(t, r.Deregister(info3))
+			assert.Eventually(t, func() bool {
+				addrs := resolveAddrs(t, res, "hertz.test.demo")
+				return len(addrs) == 1 && addrs[0] == "127.0.0.1:8889" && len(resolveAddrs(t, res, "hertz.test.other")) == 0
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestResolverReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	res := NewFileResolver(path, WithPollInterval(10*time.Millisecond))
+	// a missing file has no instance
+	assert.Empty(t, resolveAddrs(t, res, "hertz.test.demo"))
+
+	require.Nil(t, ioutil.WriteFile(path, []byte(`{"hertz.test.demo": [{"network": "tcp", "address": "127.0.0.1:8888", "weight": 10}]}`), 0o644))
+	assert.Eventually(t, func() bool {
+		addrs := resolveAddrs(t, res, "hertz.test.demo")
+		return len(addrs) == 1 && addrs[0] == "127.0.0.1:8888"
+	}, time.Second, 10*time.Millisecond)
+
+	// an invalid content keeps the last instances
+	require.Nil(t, ioutil.WriteFile(path, []byte(`{"hertz.test.demo": [`), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"127.0.0.1:8888"}, resolveAddrs(t, res, "hertz.test.demo"))
+
+	// an instance without weight has the default weight
+	require.Nil(t, ioutil.WriteFile(path, []byte(`{"hertz.test.demo": [{"network": "tcp", "address": "127.0.0.1:8889"}]}`), 0o644))
+	assert.Eventually(t, func() bool {
+		addrs := resolveAddrs(t, res, "hertz.test.demo")
+		return len(addrs) == 1 && addrs[0] == "127.0.0.1:8889"
+	}, time.Second, 10*time.Millisecond)
+	result, err := res.Resolve(context.Background(), "hertz.test.demo")
+	require.Nil(t, err)
+	assert.Equal(t, registry.DefaultWeight, result.Instances[0].Weight())
+
+	// a closed resolver stops polling and keeps the last instances
+	require.Nil(t, res.(io.Closer).Close())
+	require.Nil(t, res.(io.Closer).Close())
+	require.Nil(t, ioutil.WriteFile(path, []byte(`{"hertz.test.demo": []}`), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"127.0.0.1:8889"}, resolveAddrs(t, res, "hertz.test.demo"))
+
+	// a non-positive poll interval falls back to the default
+	res = NewFileResolver(path, WithPollInterval(0))
+	defer res.(io.Closer).Close()
+	assert.Equal(t, defaultPollInterval, res.(*fileResolver).opts.pollInterval)
+}
+
+func TestConcurrentRegister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// each registry stands for a process sharing the file
+			r := NewFileRegistry(path)
+			assert.Nil(t, r.Register(&registry.Info{
+				ServiceName: "hertz.test.demo",
+				Addr:        utils.NewNetAddr("tcp", fmt.Sprintf("127.0.0.1:%d", 8000+i)),
+				Weight:      10,
+			}))
+		}(i)
+	}
+	wg.Wait()
+	res := NewFileResolver(path)
+	assert.Len(t, resolveAddrs(t, res, "hertz.test.demo"), 20)
+}
+
+func TestRegistryInvalidInfo(t *testing.T) {
+	r := NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	assert.NotNil(t, r.Register(nil))
+	assert.NotNil(t, r.Register(&registry.Info{Addr: utils.NewNetAddr("tcp", "127.0.0.1:8888")}))
+	assert.NotNil(t, r.Register(&registry.Info{ServiceName: "hertz.test.demo"}))
+	assert.NotNil(t, r.Register(&registry.Info{ServiceName: "hertz.test.demo", Addr: utils.NewNetAddr("tcp", "127.0.0.1")}))
+}
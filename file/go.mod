@@ -0,0 +1,10 @@
+module github.com/hertz-contrib/registry/file
+
+go 1.16
+
+require (
+	github.com/cloudwego/hertz v0.9.6
+	github.com/stretchr/testify v1.10.0
+	golang.org/x/sys v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,76 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+)
+
+var _ registry.Registry = (*fileRegistry)(nil)
+
+type fileRegistry struct {
+	path string
+}
+
+// NewFileRegistry creates a registry which writes the instances into the file at path.
+// The file is JSON, or YAML if its extension is .yaml or .yml, and is created if it does not exist.
+// Several processes can register into the same file, the writes are serialized by the lock file path.lock.
+func NewFileRegistry(path string) registry.Registry {
+	return &fileRegistry{path: path}
+}
+
+// Register adds the instance into the file, replacing the instance of the service with the same address.
+func (f *fileRegistry) Register(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	addr, err := getAddress(info)
+	if err != nil {
+		return err
+	}
+	ins := instanceInfo{
+		Network: info.Addr.Network(),
+		Address: addr,
+		Weight:  info.Weight,
+		Tags:    info.Tags,
+	}
+	return update(f.path, func(s services) {
+		s[info.ServiceName] = append(removeInstance(s[info.ServiceName], addr), ins)
+	})
+}
+
+// Deregister removes the instance from the file.
+func (f *fileRegistry) Deregister(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	addr, err := getAddress(info)
+	if err != nil {
+		return err
+	}
+	return update(f.path, func(s services) {
+		s[info.ServiceName] = removeInstance(s[info.ServiceName], addr)
+	})
+}
+
+func removeInstance(instances []instanceInfo, addr string) []instanceInfo {
+	res := instances[:0]
+	for _, ins := range instances {
+		if ins.Address != addr {
+			res = append(res, ins)
+		}
+	}
+	return res
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+)
+
+var (
+	_ discovery.Resolver = (*fileResolver)(nil)
+	_ io.Closer          = (*fileResolver)(nil)
+)
+
+type fileResolver struct {
+	path string
+	opts options
+	// stop is closed by Close to stop polling the file
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.RWMutex
+	data     []byte
+	services services
+}
+
+// NewFileResolver creates a resolver which serves the instances in the file at path.
+// The file is polled for changes and reloaded when its content changes, a missing file has no instance.
+// The resolver implements io.Closer, whose Close stops polling the file.
+func NewFileResolver(path string, opts ...Option) discovery.Resolver {
+	o := options{
+		pollInterval: defaultPollInterval,
+	}
+	o.apply(opts...)
+	if o.pollInterval <= 0 {
+		o.pollInterval = defaultPollInterval
+	}
+	f := &fileResolver{
+		path:     path,
+		opts:     o,
+		stop:     make(chan struct{}),
+		services: make(services),
+	}
+	f.reload()
+	go f.poll()
+	return f
+}
+
+// Target returns the service name of the target.
+func (f *fileResolver) Target(_ context.Context, target *discovery.TargetInfo) string {
+	return target.Host
+}
+
+// Resolve returns the instances of the service in the latest content of the file.
+func (f *fileResolver) Resolve(_ context.Context, desc string) (discovery.Result, error) {
+	f.mu.RLock()
+	infos := f.services[desc]
+	f.mu.RUnlock()
+	instances := make([]discovery.Instance, 0, len(infos))
+	for _, info := range infos {
+		weight := info.Weight
+		if weight <= 0 {
+			weight = registry.DefaultWeight
+		}
+		instances = append(instances, discovery.NewInstance(info.Network, info.Address, weight, info.Tags))
+	}
+	return discovery.Result{
+		CacheKey:  desc,
+		Instances: instances,
+	}, nil
+}
+
+// Name returns the name of the resolver.
+func (f *fileResolver) Name() string {
+	return "file"
+}
+
+// Close stops polling the file, the last loaded instances are still resolved.
+func (f *fileResolver) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.stop)
+	})
+	return nil
+}
+
+func (f *fileResolver) poll() {
+	ticker := time.NewTicker(f.opts.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.reload()
+		}
+	}
+}
+
+// reload reads the file and replaces the instances if its content changed,
+// an invalid content is ignored so that the last valid instances are kept.
+func (f *fileResolver) reload() {
+	data, err := readFile(f.path)
+	if err != nil {
+		hlog.Warnf("HERTZ: read registry file %s failed: %v", f.path, err)
+		return
+	}
+	f.mu.RLock()
+	unchanged := bytes.Equal(data, f.data)
+	f.mu.RUnlock()
+	if unchanged {
+		return
+	}
+	s, err := decode(f.path, data)
+	if err != nil {
+		hlog.Warnf("HERTZ: %v", err)
+		return
+	}
+	f.mu.Lock()
+	f.data = data
+	f.services = s
+	f.mu.Unlock()
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/app/middlewares/client/sd"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hertz-contrib/registry/kubernetes"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func main() {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		panic(err)
+	}
+	k8sClient, err := k8s.NewForConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+	cli, err := client.NewClient()
+	if err != nil {
+		panic(err)
+	}
+	r := kubernetes.NewKubernetesResolver(k8sClient, kubernetes.WithNamespace("default"))
+	cli.Use(sd.Discovery(r))
+	for i := 0; i < 10; i++ {
+		status, body, err := cli.Get(context.Background(), nil, "http://hertz-demo/ping",
+			config.WithSD(true), config.WithTag(kubernetes.TagPort, "http"))
+		if err != nil {
+			hlog.Fatal(err)
+		}
+		hlog.Infof("HERTZ: code=%d,body=%s", status, string(body))
+	}
+}
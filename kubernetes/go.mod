@@ -0,0 +1,12 @@
+module github.com/hertz-contrib/registry/kubernetes
+
+go 1.16
+
+require (
+	github.com/cloudwego/hertz v0.9.6
+	github.com/hertz-contrib/registry/address v0.1.0
+	github.com/stretchr/testify v1.10.0
+	k8s.io/api v0.24.2
+	k8s.io/apimachinery v0.24.2
+	k8s.io/client-go v0.24.2
+)
@@ -0,0 +1,251 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/hertz-contrib/registry/address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newEndpointSlice(namespace, name, service string, ports map[string]int32, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+	}
+	for name, port := range ports {
+		name, port := name, port
+		slice.Ports = append(slice.Ports, discoveryv1.EndpointPort{Name: &name, Port: &port})
+	}
+	return slice
+}
+
+func newEndpoint(ip string, ready bool, zone, node string) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{ip},
+		Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+		Zone:       &zone,
+		NodeName:   &node,
+	}
+}
+
+func TestTarget(t *testing.T) {
+	r := NewKubernetesResolver(fake.NewSimpleClientset(), WithNamespace("hertz"))
+	assert.Equal(t, "hertz/demo", r.Target(context.Background(), &discovery.TargetInfo{Host: "demo"}))
+	assert.Equal(t, "prod/demo:http", r.Target(context.Background(), &discovery.TargetInfo{
+		Host: "demo",
+		Tags: map[string]string{TagNamespace: "prod", TagPort: "http"},
+	}))
+
+	namespace, service, port, err := parseDesc("prod/demo:http")
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", namespace)
+	assert.Equal(t, "demo", service)
+	assert.Equal(t, "http", port)
+	_, _, _, err = parseDesc("demo")
+	assert.NotNil(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newEndpointSlice("hertz", "demo-1", "demo", map[string]int32{"http": 8080, "admin": 9090},
+			newEndpoint("10.0.0.2", true, "zone-b", "node-2"),
+			newEndpoint("10.0.0.1", true, "zone-a", "node-1"),
+			newEndpoint("10.0.0.3", false, "zone-a", "node-1"),
+		),
+		newEndpointSlice("hertz", "other-1", "other", map[string]int32{"http": 8080},
+			newEndpoint("10.0.1.1", true, "zone-a", "node-1"),
+		),
+	)
+	r := NewKubernetesResolver(client, WithNamespace("hertz"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	desc := r.Target(ctx, &discovery.TargetInfo{Host: "demo", Tags: map[string]string{TagPort: "http"}})
+	res, err := r.Resolve(ctx, desc)
+	require.Nil(t, err)
+	assert.Equal(t, desc, res.CacheKey)
+	require.Len(t, res.Instances, 2)
+	assert.Equal(t, "10.0.0.1:8080", res.Instances[0].Address().String())
+	assert.Equal(t, "10.0.0.2:8080", res.Instances[1].Address().String())
+	assert.Equal(t, registry.DefaultWeight, res.Instances[0].Weight())
+	zone, _ := res.Instances[0].Tag(TagZone)
+	assert.Equal(t, "zone-a", zone)
+	node, _ := res.Instances[1].Tag(TagNode)
+	assert.Equal(t, "node-2", node)
+
+	// port by name
+	desc = r.Target(ctx, &discovery.TargetInfo{Host: "demo", Tags: map[string]string{TagPort: "admin"}})
+	res, err = r.Resolve(ctx, desc)
+	require.Nil(t, err)
+	require.Len(t, res.Instances, 2)
+	assert.Equal(t, "10.0.0.1:9090", res.Instances[0].Address().String())
+
+	// the only port is used without a port name
+	desc = r.Target(ctx, &discovery.TargetInfo{Host: "other"})
+	res, err = r.Resolve(ctx, desc)
+	require.Nil(t, err)
+	require.Len(t, res.Instances, 1)
+	assert.Equal(t, "10.0.1.1:8080", res.Instances[0].Address().String())
+}
+
+func TestResolveWatch(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	r := NewKubernetesResolver(client, WithNamespace("hertz"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	desc := r.Target(ctx, &discovery.TargetInfo{Host: "demo"})
+	res, err := r.Resolve(ctx, desc)
+	require.Nil(t, err)
+	assert.Empty(t, res.Instances)
+
+	slice := newEndpointSlice("hertz", "demo-1", "demo", map[string]int32{"http": 8080},
+		newEndpoint("10.0.0.1", true, "zone-a", "node-1"))
+	_, err = client.DiscoveryV1().EndpointSlices("hertz").Create(ctx, slice, metav1.CreateOptions{})
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		res, err = r.Resolve(ctx, desc)
+		return err == nil && len(res.Instances) == 1
+	}, time.Second*2, time.Millisecond*50)
+
+	slice.Endpoints = append(slice.Endpoints, newEndpoint("10.0.0.2", true, "zone-b", "node-2"))
+	_, err = client.DiscoveryV1().EndpointSlices("hertz").Update(ctx, slice, metav1.UpdateOptions{})
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		res, err = r.Resolve(ctx, desc)
+		return err == nil && len(res.Instances) == 2
+	}, time.Second*2, time.Millisecond*50)
+
+	err = client.DiscoveryV1().EndpointSlices("hertz").Delete(ctx, slice.Name, metav1.DeleteOptions{})
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		res, err = r.Resolve(ctx, desc)
+		return err == nil && len(res.Instances) == 0
+	}, time.Second*2, time.Millisecond*50)
+}
+
+func TestResolveSyncTimeout(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "endpointslices", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("endpointslices is forbidden")
+	})
+	r := NewKubernetesResolver(client, WithNamespace("hertz"), WithSyncTimeout(time.Millisecond*300))
+	defer r.(io.Closer).Close()
+
+	start := time.Now()
+	_, err := r.Resolve(context.Background(), r.Target(context.Background(), &discovery.TargetInfo{Host: "demo"}))
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second*3))
+}
+
+func TestResolverClose(t *testing.T) {
+	r := NewKubernetesResolver(fake.NewSimpleClientset(), WithNamespace("hertz"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	desc := r.Target(ctx, &discovery.TargetInfo{Host: "demo"})
+	_, err := r.Resolve(ctx, desc)
+	require.Nil(t, err)
+
+	w := r.(*kubernetesResolver).watchers["hertz/demo"]
+	require.NotNil(t, w)
+	require.Nil(t, r.(io.Closer).Close())
+	select {
+	case <-w.stop:
+	default:
+		t.Fatal("informer is not stopped")
+	}
+	_, err = r.Resolve(ctx, desc)
+	assert.Equal(t, ErrResolverClosed, err)
+	// closing again is a no-op
+	assert.Nil(t, r.(io.Closer).Close())
+}
+
+func TestRegistry(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "hertz", Name: "demo-0"}}
+	client := fake.NewSimpleClientset(pod)
+	info := &registry.Info{
+		ServiceName: "hertz.test.demo",
+		Addr:        utils.NewNetAddr("tcp", "10.0.0.1:8888"),
+		Weight:      10,
+		Tags:        map[string]string{"hello": "world"},
+	}
+	ctx := context.Background()
+
+	// no-op without a pod
+	r := NewKubernetesRegistry(client)
+	assert.Nil(t, r.Register(info))
+	assert.Nil(t, r.Deregister(info))
+
+	r = NewKubernetesRegistry(client, WithPod("hertz", "demo-0"))
+	assert.Nil(t, r.Register(info))
+	got, err := client.CoreV1().Pods("hertz").Get(ctx, "demo-0", metav1.GetOptions{})
+	require.Nil(t, err)
+	var ins instanceInfo
+	require.Nil(t, json.Unmarshal([]byte(got.Annotations[annotationKey(info.ServiceName)]), &ins))
+	assert.Equal(t, "10.0.0.1:8888", ins.Address)
+	assert.Equal(t, 10, ins.Weight)
+	assert.Equal(t, info.Tags, ins.Tags)
+
+	assert.Nil(t, r.Deregister(info))
+	got, err = client.CoreV1().Pods("hertz").Get(ctx, "demo-0", metav1.GetOptions{})
+	require.Nil(t, err)
+	_, ok := got.Annotations[annotationKey(info.ServiceName)]
+	assert.False(t, ok)
+
+	// the unspecified listening address is replaced by the pod IP
+	require.Nil(t, os.Setenv(podIPEnv, "10.0.0.2"))
+	defer os.Unsetenv(podIPEnv)
+	r = NewKubernetesRegistry(client, WithPod("hertz", "demo-0"))
+	assert.Nil(t, r.Register(&registry.Info{
+		ServiceName: info.ServiceName,
+		Addr:        utils.NewNetAddr("tcp", "0.0.0.0:8888"),
+		Weight:      10,
+	}))
+	got, err = client.CoreV1().Pods("hertz").Get(ctx, "demo-0", metav1.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal([]byte(got.Annotations[annotationKey(info.ServiceName)]), &ins))
+	assert.Equal(t, "10.0.0.2:8888", ins.Address)
+
+	// the address options override the pod IP
+	r = NewKubernetesRegistry(client, WithPod("hertz", "demo-0"), WithAddressOptions(address.WithAdvertiseAddr("10.0.0.3:9999")))
+	assert.Nil(t, r.Register(info))
+	got, err = client.CoreV1().Pods("hertz").Get(ctx, "demo-0", metav1.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal([]byte(got.Annotations[annotationKey(info.ServiceName)]), &ins))
+	assert.Equal(t, "10.0.0.3:9999", ins.Address)
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"os"
+	"time"
+
+	"github.com/hertz-contrib/registry/address"
+)
+
+const (
+	// TagNamespace is the key of discovery.TargetInfo.Tags for the namespace of the service.
+	TagNamespace = "namespace"
+	// TagPort is the key of discovery.TargetInfo.Tags for the name of the service port.
+	TagPort = "port"
+	// TagZone is the key of discovery.Instance tags for the zone of the endpoint.
+	TagZone = "zone"
+	// TagNode is the key of discovery.Instance tags for the node name of the endpoint.
+	TagNode = "node"
+
+	defaultNamespace    = "default"
+	defaultResyncPeriod = 10 * time.Minute
+	defaultSyncTimeout  = 10 * time.Second
+	podNameEnv          = "POD_NAME"
+	podNamespaceEnv     = "POD_NAMESPACE"
+	podIPEnv            = "POD_IP"
+	annotationPrefix    = "registry.hertz.cloudwego.io/"
+)
+
+type resolverOptions struct {
+	namespace    string
+	resyncPeriod time.Duration
+	syncTimeout  time.Duration
+}
+
+// ResolverOption is the option of kubernetes resolver.
+type ResolverOption func(o *resolverOptions)
+
+// WithNamespace sets the namespace of the services which are resolved without the namespace tag.
+// Default: the namespace in env POD_NAMESPACE, or "default"
+func WithNamespace(namespace string) ResolverOption {
+	return func(o *resolverOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithResyncPeriod sets the resync period of the EndpointSlice informers.
+// Default: 10m
+func WithResyncPeriod(period time.Duration) ResolverOption {
+	return func(o *resolverOptions) {
+		o.resyncPeriod = period
+	}
+}
+
+// WithSyncTimeout sets the maximum time to wait for the first list of the EndpointSlices of a service,
+// e.g. when the API server is unreachable or the EndpointSlices are forbidden to list.
+// Default: 10s
+func WithSyncTimeout(timeout time.Duration) ResolverOption {
+	return func(o *resolverOptions) {
+		o.syncTimeout = timeout
+	}
+}
+
+func newResolverOptions(opts ...ResolverOption) resolverOptions {
+	o := resolverOptions{
+		namespace:    defaultNamespace,
+		resyncPeriod: defaultResyncPeriod,
+		syncTimeout:  defaultSyncTimeout,
+	}
+	if ns, ok := os.LookupEnv(podNamespaceEnv); ok && ns != "" {
+		o.namespace = ns
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type registryOptions struct {
+	podNamespace string
+	podName      string
+	addressOpts  []address.Option
+}
+
+// RegistryOption is the option of kubernetes registry.
+type RegistryOption func(o *registryOptions)
+
+// WithPod sets the pod whose annotations the registry patches.
+// Default: the pod in env POD_NAMESPACE and POD_NAME
+func WithPod(namespace, name string) RegistryOption {
+	return func(o *registryOptions) {
+		o.podNamespace = namespace
+		o.podName = name
+	}
+}
+
+// WithAddressOptions sets how the registry decides the address to register, see the address package.
+// Default: the pod IP in env POD_IP with the listening port, or the host detected by the address package
+func WithAddressOptions(opts ...address.Option) RegistryOption {
+	return func(o *registryOptions) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
+
+func newRegistryOptions(opts ...RegistryOption) registryOptions {
+	o := registryOptions{
+		podNamespace: os.Getenv(podNamespaceEnv),
+		podName:      os.Getenv(podNameEnv),
+	}
+	if ip := os.Getenv(podIPEnv); ip != "" {
+		o.addressOpts = append(o.addressOpts, address.WithAdvertiseAddr(ip))
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
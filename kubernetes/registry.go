@@ -0,0 +1,131 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hertz-contrib/registry/address"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+var _ registry.Registry = (*kubernetesRegistry)(nil)
+
+// kubernetesRegistry records the registered services as annotations of the pod of the server.
+// Kubernetes itself maintains the endpoints of a service, so the registry is a no-op when
+// the pod is unknown.
+type kubernetesRegistry struct {
+	client kubernetes.Interface
+	opts   registryOptions
+}
+
+// instanceInfo is stored as the value of the annotation of a registered service.
+type instanceInfo struct {
+	Network string            `json:"network"`
+	Address string            `json:"address"`
+	Weight  int               `json:"weight"`
+	Tags    map[string]string `json:"tags"`
+}
+
+// NewKubernetesRegistry creates a kubernetes registry.
+func NewKubernetesRegistry(client kubernetes.Interface, opts ...RegistryOption) registry.Registry {
+	return &kubernetesRegistry{
+		client: client,
+		opts:   newRegistryOptions(opts...),
+	}
+}
+
+// Register sets the annotation of the service on the pod.
+func (k *kubernetesRegistry) Register(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	if !k.hasPod() {
+		hlog.Debugf("HERTZ: pod is unknown, skip registering service %s to kubernetes", info.ServiceName)
+		return nil
+	}
+	addr, err := address.Advertise(info.Addr, k.opts.addressOpts...)
+	if err != nil {
+		return fmt.Errorf("parse registry info addr error: %w", err)
+	}
+	val, err := json.Marshal(&instanceInfo{
+		Network: info.Addr.Network(),
+		Address: addr,
+		Weight:  info.Weight,
+		Tags:    info.Tags,
+	})
+	if err != nil {
+		return err
+	}
+	return k.patchAnnotation(annotationKey(info.ServiceName), string(val))
+}
+
+// Deregister removes the annotation of the service from the pod.
+func (k *kubernetesRegistry) Deregister(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	if !k.hasPod() {
+		return nil
+	}
+	return k.patchAnnotation(annotationKey(info.ServiceName), nil)
+}
+
+func (k *kubernetesRegistry) hasPod() bool {
+	return k.opts.podNamespace != "" && k.opts.podName != ""
+}
+
+// patchAnnotation sets the annotation of the pod to value, or removes it if value is nil.
+func (k *kubernetesRegistry) patchAnnotation(key string, value interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				key: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_, err = k.client.CoreV1().Pods(k.opts.podNamespace).Patch(ctx, k.opts.podName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+func annotationKey(serviceName string) string {
+	return annotationPrefix + serviceName
+}
+
+// validateRegistryInfo validate the registry.Info
+func validateRegistryInfo(info *registry.Info) error {
+	if info == nil {
+		return fmt.Errorf("registry.Info can not be empty")
+	}
+	if info.ServiceName == "" {
+		return fmt.Errorf("registry.Info ServiceName can not be empty")
+	}
+	if info.Addr == nil {
+		return fmt.Errorf("registry.Info Addr can not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultNetwork = "tcp"
+
+var (
+	_ discovery.Resolver = (*kubernetesResolver)(nil)
+	_ io.Closer          = (*kubernetesResolver)(nil)
+)
+
+// ErrResolverClosed is returned by Resolve after the resolver is closed.
+var ErrResolverClosed = errors.New("kubernetes resolver is closed")
+
+type kubernetesResolver struct {
+	client kubernetes.Interface
+	opts   resolverOptions
+
+	mu       sync.Mutex
+	watchers map[string]*serviceWatcher
+	closed   bool
+}
+
+// serviceWatcher watches the EndpointSlices of one service.
+type serviceWatcher struct {
+	selector labels.Selector
+	lister   discoverylisters.EndpointSliceNamespaceLister
+	synced   cache.InformerSynced
+	stop     chan struct{}
+}
+
+// NewKubernetesResolver creates a resolver which resolves the host of a target as the name of
+// a kubernetes service, using the ready endpoints of its EndpointSlices.
+// The resolver implements io.Closer, whose Close stops the informers.
+func NewKubernetesResolver(client kubernetes.Interface, opts ...ResolverOption) discovery.Resolver {
+	return &kubernetesResolver{
+		client:   client,
+		opts:     newResolverOptions(opts...),
+		watchers: make(map[string]*serviceWatcher),
+	}
+}
+
+// Target returns the description of the target in the format of {namespace}/{service}[:{port}],
+// the namespace and port name are taken from the tags of the target.
+func (k *kubernetesResolver) Target(_ context.Context, target *discovery.TargetInfo) string {
+	namespace := k.opts.namespace
+	if ns, ok := target.Tags[TagNamespace]; ok && ns != "" {
+		namespace = ns
+	}
+	desc := namespace + "/" + target.Host
+	if port, ok := target.Tags[TagPort]; ok && port != "" {
+		desc += ":" + port
+	}
+	return desc
+}
+
+// Resolve returns the ready endpoints of the service described by desc.
+func (k *kubernetesResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	namespace, service, port, err := parseDesc(desc)
+	if err != nil {
+		return discovery.Result{}, err
+	}
+	w, err := k.getWatcher(namespace, service)
+	if err != nil {
+		return discovery.Result{}, err
+	}
+	// the ctx of the first resolve usually has no deadline, which must not wait forever for an informer failing to list
+	syncCtx, cancel := context.WithTimeout(ctx, k.opts.syncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), w.synced) {
+		return discovery.Result{}, fmt.Errorf("sync EndpointSlices of service %s/%s failed", namespace, service)
+	}
+	slices, err := w.lister.List(w.selector)
+	if err != nil {
+		return discovery.Result{}, err
+	}
+	return discovery.Result{
+		CacheKey:  desc,
+		Instances: convertEndpointSlices(slices, port),
+	}, nil
+}
+
+// Name returns the name of the resolver.
+func (k *kubernetesResolver) Name() string {
+	return "kubernetes"
+}
+
+// Close stops the informers of the resolver.
+func (k *kubernetesResolver) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return nil
+	}
+	k.closed = true
+	for _, w := range k.watchers {
+		close(w.stop)
+	}
+	k.watchers = make(map[string]*serviceWatcher)
+	return nil
+}
+
+// getWatcher returns the watcher of the service, starting an informer for it if needed.
+func (k *kubernetesResolver) getWatcher(namespace, service string) (*serviceWatcher, error) {
+	key := namespace + "/" + service
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.closed {
+		return nil, ErrResolverClosed
+	}
+	if w, ok := k.watchers[key]; ok {
+		return w, nil
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service})
+	factory := informers.NewSharedInformerFactoryWithOptions(k.client, k.opts.resyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = selector.String()
+		}),
+	)
+	informer := factory.Discovery().V1().EndpointSlices()
+	w := &serviceWatcher{
+		selector: selector,
+		lister:   informer.Lister().EndpointSlices(namespace),
+		synced:   informer.Informer().HasSynced,
+		stop:     make(chan struct{}),
+	}
+	factory.Start(w.stop)
+	k.watchers[key] = w
+	return w, nil
+}
+
+// parseDesc parses the description built by Target.
+func parseDesc(desc string) (namespace, service, port string, err error) {
+	i := strings.Index(desc, "/")
+	if i <= 0 || i == len(desc)-1 {
+		return "", "", "", fmt.Errorf("invalid kubernetes service description %q", desc)
+	}
+	namespace, service = desc[:i], desc[i+1:]
+	if j := strings.LastIndex(service, ":"); j >= 0 {
+		service, port = service[:j], service[j+1:]
+	}
+	return namespace, service, port, nil
+}
+
+// convertEndpointSlices returns the ready endpoints of the slices on the port with the given name.
+// If the name is empty, the only port or the unnamed port of each slice is used.
+func convertEndpointSlices(slices []*discoveryv1.EndpointSlice, portName string) []discovery.Instance {
+	seen := make(map[string]struct{})
+	var addrs []string
+	instances := make(map[string]discovery.Instance)
+	for _, slice := range slices {
+		if slice.AddressType != discoveryv1.AddressTypeIPv4 && slice.AddressType != discoveryv1.AddressTypeIPv6 {
+			continue
+		}
+		port, ok := selectPort(slice.Ports, portName)
+		if !ok {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			tags := make(map[string]string)
+			if ep.Zone != nil && *ep.Zone != "" {
+				tags[TagZone] = *ep.Zone
+			}
+			if ep.NodeName != nil && *ep.NodeName != "" {
+				tags[TagNode] = *ep.NodeName
+			}
+			for _, ip := range ep.Addresses {
+				addr := net.JoinHostPort(ip, strconv.Itoa(int(port)))
+				if _, ok := seen[addr]; ok {
+					continue
+				}
+				seen[addr] = struct{}{}
+				addrs = append(addrs, addr)
+				instances[addr] = discovery.NewInstance(defaultNetwork, addr, registry.DefaultWeight, tags)
+			}
+		}
+	}
+	sort.Strings(addrs)
+	result := make([]discovery.Instance, 0, len(addrs))
+	for _, addr := range addrs {
+		result = append(result, instances[addr])
+	}
+	return result
+}
+
+func selectPort(ports []discoveryv1.EndpointPort, name string) (int32, bool) {
+	for _, p := range ports {
+		if p.Port == nil {
+			continue
+		}
+		if name != "" {
+			if p.Name != nil && *p.Name == name {
+				return *p.Port, true
+			}
+			continue
+		}
+		if len(ports) == 1 || p.Name == nil || *p.Name == "" {
+			return *p.Port, true
+		}
+	}
+	return 0, false
+}
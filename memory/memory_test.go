@@ -0,0 +1,187 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInfo(addr string) *registry.Info {
+	return &registry.Info{
+		ServiceName: "hertz.test.demo",
+		Addr:        utils.NewNetAddr("tcp", addr),
+		Weight:      10,
+		Tags:        map[string]string{"key1": "value1"},
+	}
+}
+
+func resolveAddrs(t *testing.T, r discovery.Resolver) []string {
+	res, err := r.Resolve(context.Background(), "hertz.test.demo")
+	require.Nil(t, err)
+	var addrs []string
+	for _, ins := range res.Instances {
+		addrs = append(addrs, ins.Address().String())
+	}
+	return addrs
+}
+
+func TestRegistryAndResolver(t *testing.T) {
+	store := NewStore()
+	r := NewMemoryRegistry(store)
+	res := NewMemoryResolver(store)
+
+	info1, info2 := newInfo("127.0.0.1:8888"), newInfo("127.0.0.1:8889")
+	require.Nil(t, r.Register(info2))
+	require.Nil(t, r.Register(info1))
+	require.Nil(t, r.Register(info1))
+
+	desc := res.Target(context.Background(), &discovery.TargetInfo{Host: "hertz.test.demo"})
+	assert.Equal(t, "hertz.test.demo", desc)
+	result, err := res.Resolve(context.Background(), desc)
+	require.Nil(t, err)
+	assert.Equal(t, desc, result.CacheKey)
+	require.Len(t, result.Instances, 2)
+	assert.Equal(t, "127.0.0.1:8888", result.Instances[0].Address().String())
+	assert.Equal(t, 10, result.Instances[0].Weight())
+	value, ok := result.Instances[0].Tag("key1")
+	assert.True(t, ok)
+	assert.Equal(t, "value1", value)
+
+	require.Nil(t, r.Deregister(info1))
+	assert.Equal(t, []string{"127.0.0.1:8889"}, resolveAddrs(t, res))
+	require.Nil(t, r.Deregister(info2))
+	assert.Empty(t, resolveAddrs(t, res))
+
+	// an unspecified host is registered as the loopback address
+	info3 := newInfo("0.0.0.0:8890")
+	require.Nil(t, r.Register(info3))
+	assert.Equal(t, []string{"127.0.0.1:8890"}, resolveAddrs(t, res))
+	require.Nil(t, r.Deregister(info3))
+	assert.Empty(t, resolveAddrs(t, res))
+
+	assert.NotNil(t, r.Register(nil))
+	assert.NotNil(t, r.Register(&registry.Info{ServiceName: "hertz.test.demo"}))
+}
+
+func TestTTL(t *testing.T) {
+	store := NewStore()
+	res := NewMemoryResolver(store)
+
+	// an instance without heartbeat expires
+	require.Nil(t, store.Register(newInfo("127.0.0.1:8888"), 50*time.Millisecond))
+	assert.Equal(t, []string{"127.0.0.1:8888"}, resolveAddrs(t, res))
+	assert.Eventually(t, func() bool {
+		return len(resolveAddrs(t, res)) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// the registry keeps its instances alive
+	r := NewMemoryRegistry(store, WithTTL(50*time.Millisecond))
+	info := newInfo("127.0.0.1:8889")
+	require.Nil(t, r.Register(info))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, []string{"127.0.0.1:8889"}, resolveAddrs(t, res))
+	require.Nil(t, r.Deregister(info))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, resolveAddrs(t, res))
+
+	// a ttl shorter than the refresh interval does not panic
+	r = NewMemoryRegistry(store, WithTTL(time.Nanosecond))
+	require.Nil(t, r.Register(info))
+	time.Sleep(10 * time.Millisecond)
+	require.Nil(t, r.Deregister(info))
+}
+
+func TestSubscribe(t *testing.T) {
+	store := NewStore()
+	r := NewMemoryRegistry(store)
+
+	var mu sync.Mutex
+	var changes [][]string
+	unsubscribe := store.Subscribe("hertz.test.demo", func(desc string, result discovery.Result) {
+		assert.Equal(t, "hertz.test.demo", desc)
+		var addrs []string
+		for _, ins := range result.Instances {
+			addrs = append(addrs, ins.Address().String())
+		}
+		mu.Lock()
+		changes = append(changes, addrs)
+		mu.Unlock()
+	})
+
+	info1, info2 := newInfo("127.0.0.1:8888"), newInfo("127.0.0.1:8889")
+	require.Nil(t, r.Register(info1))
+	require.Nil(t, r.Register(info2))
+	require.Nil(t, r.Deregister(info1))
+	// deregistering a missing instance is not a change
+	require.Nil(t, r.Deregister(info1))
+	// other services are not notified
+	require.Nil(t, r.Register(&registry.Info{ServiceName: "hertz.test.other", Addr: utils.NewNetAddr("tcp", "127.0.0.1:9999")}))
+	unsubscribe()
+	require.Nil(t, r.Deregister(info2))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]string{
+		{"127.0.0.1:8888"},
+		{"127.0.0.1:8888", "127.0.0.1:8889"},
+		{"127.0.0.1:8889"},
+	}, changes)
+}
+
+func TestFaultInjection(t *testing.T) {
+	store := NewStore()
+	r := NewMemoryRegistry(store)
+	res := NewMemoryResolver(store)
+	require.Nil(t, r.Register(newInfo("127.0.0.1:8888")))
+
+	errBoom := errors.New("boom")
+	store.InjectResolveError(2, errBoom)
+	for i := 0; i < 2; i++ {
+		_, err := res.Resolve(context.Background(), "hertz.test.demo")
+		assert.Equal(t, errBoom, err)
+	}
+	assert.Equal(t, []string{"127.0.0.1:8888"}, resolveAddrs(t, res))
+
+	store.InjectResolveError(-1, nil)
+	for i := 0; i < 3; i++ {
+		_, err := res.Resolve(context.Background(), "hertz.test.demo")
+		assert.Equal(t, ErrInjected, err)
+	}
+	store.InjectResolveError(0, nil)
+	assert.Equal(t, []string{"127.0.0.1:8888"}, resolveAddrs(t, res))
+
+	store.InjectRegisterError(1, nil)
+	assert.Equal(t, ErrInjected, r.Register(newInfo("127.0.0.1:8889")))
+	assert.Nil(t, r.Register(newInfo("127.0.0.1:8889")))
+
+	store.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	assert.Len(t, resolveAddrs(t, res), 2)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := res.Resolve(ctx, "hertz.test.demo")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import "time"
+
+type registryOptions struct {
+	ttl time.Duration
+}
+
+// RegistryOption is the option of memory registry.
+type RegistryOption func(o *registryOptions)
+
+// WithTTL makes the registered instances expire after ttl, the registry refreshes them every ttl/3, but at most
+// once a millisecond, until they are deregistered.
+// Default: 0, the instances never expire
+func WithTTL(ttl time.Duration) RegistryOption {
+	return func(o *registryOptions) {
+		o.ttl = ttl
+	}
+}
+
+func (o *registryOptions) apply(opts ...RegistryOption) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+)
+
+var _ registry.Registry = (*memoryRegistry)(nil)
+
+// minRefreshInterval bounds how often the instances are refreshed, whatever the ttl is
+const minRefreshInterval = time.Millisecond
+
+type memoryRegistry struct {
+	store *Store
+	opts  registryOptions
+
+	mu         sync.Mutex
+	keepalives map[string]context.CancelFunc
+}
+
+// NewMemoryRegistry creates a registry which registers the instances into store.
+func NewMemoryRegistry(store *Store, opts ...RegistryOption) registry.Registry {
+	o := registryOptions{}
+	o.apply(opts...)
+	return &memoryRegistry{
+		store:      store,
+		opts:       o,
+		keepalives: make(map[string]context.CancelFunc),
+	}
+}
+
+// Register adds the instance into the store and keeps it alive if a ttl is set.
+func (m *memoryRegistry) Register(info *registry.Info) error {
+	if err := m.store.Register(info, m.opts.ttl); err != nil {
+		return err
+	}
+	if m.opts.ttl <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	// registering the same instance again replaces its keepalive
+	if prev, ok := m.keepalives[instanceKey(info)]; ok {
+		prev()
+	}
+	m.keepalives[instanceKey(info)] = cancel
+	m.mu.Unlock()
+	go m.keepAlive(ctx, info)
+	return nil
+}
+
+// Deregister stops the keepalive of the instance and removes it from the store.
+func (m *memoryRegistry) Deregister(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	if cancel, ok := m.keepalives[instanceKey(info)]; ok {
+		cancel()
+		delete(m.keepalives, instanceKey(info))
+	}
+	m.mu.Unlock()
+	return m.store.Deregister(info)
+}
+
+func (m *memoryRegistry) keepAlive(ctx context.Context, info *registry.Info) {
+	interval := m.opts.ttl / 3
+	if interval < minRefreshInterval {
+		interval = minRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// refresh under the lock, so that a concurrent Deregister can not be undone
+			m.mu.Lock()
+			if ctx.Err() == nil {
+				m.store.refresh(info, m.opts.ttl)
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+func instanceKey(info *registry.Info) string {
+	return info.ServiceName + "/" + address(info)
+}
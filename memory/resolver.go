@@ -0,0 +1,54 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+)
+
+var _ discovery.Resolver = (*memoryResolver)(nil)
+
+type memoryResolver struct {
+	store *Store
+}
+
+// NewMemoryResolver creates a resolver which resolves the instances in store.
+func NewMemoryResolver(store *Store) discovery.Resolver {
+	return &memoryResolver{store: store}
+}
+
+// Target returns the service name of the target.
+func (m *memoryResolver) Target(_ context.Context, target *discovery.TargetInfo) string {
+	return target.Host
+}
+
+// Resolve returns the instances of desc in the store, subject to the injected failures and latency.
+func (m *memoryResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	instances, err := m.store.resolve(ctx, desc)
+	if err != nil {
+		return discovery.Result{}, err
+	}
+	return discovery.Result{
+		CacheKey:  desc,
+		Instances: instances,
+	}, nil
+}
+
+// Name returns the name of the resolver.
+func (m *memoryResolver) Name() string {
+	return "memory"
+}
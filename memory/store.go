@@ -0,0 +1,332 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+)
+
+// ErrInjected is the default error returned by an injected failure.
+var ErrInjected = errors.New("memory: injected failure")
+
+// ChangeNotifyFunc is called with the latest instances of a service after they change.
+type ChangeNotifyFunc func(desc string, result discovery.Result)
+
+// Store is an in-process registry shared by the registries and resolvers created from it.
+type Store struct {
+	// notifyMu serializes the changes with their notifications, so that
+	// subscribers observe the changes of a service in order.
+	notifyMu sync.Mutex
+
+	mu          sync.Mutex
+	services    map[string]map[string]*entry
+	subscribers map[string]map[int]ChangeNotifyFunc
+	nextID      int
+
+	resolveFault  fault
+	registerFault fault
+	latency       time.Duration
+}
+
+type entry struct {
+	network string
+	address string
+	weight  int
+	tags    map[string]string
+	// timer expires the entry, nil if the entry never expires
+	timer *time.Timer
+}
+
+type fault struct {
+	n   int
+	err error
+}
+
+// take consumes a failure, n < 0 fails forever.
+func (f *fault) take() error {
+	if f.n == 0 {
+		return nil
+	}
+	if f.n > 0 {
+		f.n--
+	}
+	return f.err
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		services:    make(map[string]map[string]*entry),
+		subscribers: make(map[string]map[int]ChangeNotifyFunc),
+	}
+}
+
+// Register adds an instance of info.ServiceName, replacing the instance with the same address.
+// If ttl > 0, the instance expires after ttl unless it is registered again,
+// which simulates a server stopping its heartbeat.
+func (s *Store) Register(info *registry.Info, ttl time.Duration) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	_ = s.wait(context.Background())
+	s.mu.Lock()
+	err := s.registerFault.take()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.add(info, ttl)
+	return nil
+}
+
+func (s *Store) add(info *registry.Info, ttl time.Duration) {
+	e := &entry{
+		network: info.Addr.Network(),
+		address: address(info),
+		weight:  info.Weight,
+		tags:    info.Tags,
+	}
+	s.update(info.ServiceName, func(instances map[string]*entry) bool {
+		if prev, ok := instances[e.address]; ok && prev.timer != nil {
+			prev.timer.Stop()
+		}
+		if ttl > 0 {
+			e.timer = time.AfterFunc(ttl, func() {
+				s.expire(info.ServiceName, e)
+			})
+		}
+		instances[e.address] = e
+		return true
+	})
+}
+
+// Deregister removes the instance of info.ServiceName with the address of info.
+func (s *Store) Deregister(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+	_ = s.wait(context.Background())
+	s.update(info.ServiceName, func(instances map[string]*entry) bool {
+		addr := address(info)
+		e, ok := instances[addr]
+		if !ok {
+			return false
+		}
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+		delete(instances, addr)
+		return true
+	})
+	return nil
+}
+
+// refresh resets the ttl of the instance of info, or adds it again if it has expired.
+// Unlike Register, it ignores the injected failures, which only apply to the calls of the user.
+func (s *Store) refresh(info *registry.Info, ttl time.Duration) {
+	s.mu.Lock()
+	e, ok := s.services[info.ServiceName][address(info)]
+	if ok && e.timer != nil {
+		e.timer.Reset(ttl)
+	}
+	s.mu.Unlock()
+	if !ok {
+		s.add(info, ttl)
+	}
+}
+
+func (s *Store) expire(service string, e *entry) {
+	s.update(service, func(instances map[string]*entry) bool {
+		// the entry may have been replaced since the timer fired
+		if instances[e.address] != e {
+			return false
+		}
+		delete(instances, e.address)
+		return true
+	})
+}
+
+// Instances returns the instances of service, sorted by address.
+func (s *Store) Instances(service string) []discovery.Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.instances(service)
+}
+
+func (s *Store) instances(service string) []discovery.Instance {
+	entries := s.services[service]
+	addrs := make([]string, 0, len(entries))
+	for addr := range entries {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	instances := make([]discovery.Instance, 0, len(addrs))
+	for _, addr := range addrs {
+		e := entries[addr]
+		instances = append(instances, discovery.NewInstance(e.network, e.address, e.weight, e.tags))
+	}
+	return instances
+}
+
+// Subscribe calls fn with the latest instances of service after they change, until the returned function is called.
+// fn is called synchronously and must not register or deregister instances.
+func (s *Store) Subscribe(service string, fn ChangeNotifyFunc) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	if s.subscribers[service] == nil {
+		s.subscribers[service] = make(map[int]ChangeNotifyFunc)
+	}
+	s.subscribers[service][id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[service], id)
+		if len(s.subscribers[service]) == 0 {
+			delete(s.subscribers, service)
+		}
+	}
+}
+
+// InjectResolveError makes the next n resolves fail with err, or all resolves if n < 0.
+// A nil err is replaced by ErrInjected, and n == 0 stops the failures.
+func (s *Store) InjectResolveError(n int, err error) {
+	if err == nil {
+		err = ErrInjected
+	}
+	s.mu.Lock()
+	s.resolveFault = fault{n: n, err: err}
+	s.mu.Unlock()
+}
+
+// InjectRegisterError makes the next n registrations fail with err, or all registrations if n < 0.
+// A nil err is replaced by ErrInjected, and n == 0 stops the failures.
+func (s *Store) InjectRegisterError(n int, err error) {
+	if err == nil {
+		err = ErrInjected
+	}
+	s.mu.Lock()
+	s.registerFault = fault{n: n, err: err}
+	s.mu.Unlock()
+}
+
+// SetLatency delays every resolve, registration and deregistration by d.
+func (s *Store) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// wait sleeps for the injected latency, or until ctx is done.
+func (s *Store) wait(ctx context.Context) error {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	if latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Store) resolve(ctx context.Context, desc string) ([]discovery.Instance, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveFault.take(); err != nil {
+		return nil, err
+	}
+	return s.instances(desc), nil
+}
+
+// update runs fn on the instances of service and notifies the subscribers of service if fn changed them.
+func (s *Store) update(service string, fn func(instances map[string]*entry) bool) {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	s.mu.Lock()
+	instances, ok := s.services[service]
+	if !ok {
+		instances = make(map[string]*entry)
+		s.services[service] = instances
+	}
+	changed := fn(instances)
+	if len(instances) == 0 {
+		delete(s.services, service)
+	}
+	if !changed {
+		s.mu.Unlock()
+		return
+	}
+	result := discovery.Result{
+		CacheKey:  service,
+		Instances: s.instances(service),
+	}
+	subscribers := make([]ChangeNotifyFunc, 0, len(s.subscribers[service]))
+	for _, fn := range s.subscribers[service] {
+		subscribers = append(subscribers, fn)
+	}
+	s.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(service, result)
+	}
+}
+
+func validateRegistryInfo(info *registry.Info) error {
+	if info == nil {
+		return fmt.Errorf("registry.Info can not be empty")
+	}
+	if info.ServiceName == "" {
+		return fmt.Errorf("registry.Info ServiceName can not be empty")
+	}
+	if info.Addr == nil {
+		return fmt.Errorf("registry.Info Addr can not be empty")
+	}
+	return nil
+}
+
+// address returns the address of info, an unspecified host is replaced by
+// the loopback address since the instances are only used in process.
+func address(info *registry.Info) string {
+	addr := info.Addr.String()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
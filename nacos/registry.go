@@ -16,12 +16,10 @@ package nacos
 
 import (
 	"fmt"
-	"net"
-	"strconv"
 
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
-	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/hertz-contrib/registry/nacos/common"
 	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
 	"github.com/nacos-group/nacos-sdk-go/vo"
@@ -36,8 +34,9 @@ type (
 	}
 
 	registryOptions struct {
-		cluster string
-		group   string
+		cluster     string
+		group       string
+		addressOpts []address.Option
 	}
 
 	// RegistryOption Option is nacos registry option.
@@ -58,22 +57,22 @@ func WithRegistryGroup(group string) RegistryOption {
 	}
 }
 
+// WithRegistryAddressOptions with the options deciding the address to register, see the address package.
+func WithRegistryAddressOptions(opts ...address.Option) RegistryOption {
+	return func(o *registryOptions) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
+
 func (n *nacosRegistry) Register(info *registry.Info) error {
 	if err := n.validRegistryInfo(info); err != nil {
 		return fmt.Errorf("valid parse registry info error: %w", err)
 	}
 
-	host, port, err := net.SplitHostPort(info.Addr.String())
+	host, p, err := address.AdvertiseHostPort(info.Addr, n.opts.addressOpts...)
 	if err != nil {
 		return fmt.Errorf("parse registry info addr error: %w", err)
 	}
-	p, err := strconv.Atoi(port)
-	if err != nil {
-		return fmt.Errorf("parse registry info port error: %w", err)
-	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
-	}
 	success, err := n.client.RegisterInstance(vo.RegisterInstanceParam{
 		Ip:          host,
 		Port:        uint64(p),
@@ -113,17 +112,10 @@ func (n *nacosRegistry) Deregister(info *registry.Info) error {
 	if err := n.validRegistryInfo(info); err != nil {
 		return fmt.Errorf("valid parse registry info error: %w", err)
 	}
-	host, port, err := net.SplitHostPort(info.Addr.String())
+	host, portInt, err := address.AdvertiseHostPort(info.Addr, n.opts.addressOpts...)
 	if err != nil {
 		return err
 	}
-	portInt, err := strconv.Atoi(port)
-	if err != nil {
-		return fmt.Errorf("parse registry info port error: %w", err)
-	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
-	}
 	success, err := n.client.DeregisterInstance(vo.DeregisterInstanceParam{
 		Ip:          host,
 		Port:        uint64(portInt),
@@ -141,6 +133,37 @@ func (n *nacosRegistry) Deregister(info *registry.Info) error {
 	return nil
 }
 
+// Drain disables the instance, so that it is no longer resolved while it is still registered.
+func (n *nacosRegistry) Drain(info *registry.Info) error {
+	if err := n.validRegistryInfo(info); err != nil {
+		return fmt.Errorf("valid parse registry info error: %w", err)
+	}
+	host, portInt, err := address.AdvertiseHostPort(info.Addr, n.opts.addressOpts...)
+	if err != nil {
+		return err
+	}
+	// unlike v2, the UpdateInstanceParam of nacos-sdk-go v1 has no Healthy field and the update does not
+	// change the health of the instance, which stays healthy as UpdateInstance keeps its heartbeat
+	success, err := n.client.UpdateInstance(vo.UpdateInstanceParam{
+		Ip:          host,
+		Port:        uint64(portInt),
+		ServiceName: info.ServiceName,
+		GroupName:   n.opts.group,
+		ClusterName: n.opts.cluster,
+		Weight:      float64(info.Weight),
+		Enable:      false,
+		Ephemeral:   true,
+		Metadata:    info.Tags,
+	})
+	if success {
+		hlog.Info("HERTZ: drain instance success")
+	}
+	if err != nil {
+		return fmt.Errorf("drain instance error: %w", err)
+	}
+	return nil
+}
+
 // NewDefaultNacosRegistry create a default service registry using nacos.
 func NewDefaultNacosRegistry(opts ...RegistryOption) (registry.Registry, error) {
 	client, err := common.NewDefaultNacosConfig()
@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/cloudwego/hertz v0.9.6
+	github.com/hertz-contrib/registry/address v0.1.0
 	github.com/nacos-group/nacos-sdk-go/v2 v2.2.0
 	github.com/stretchr/testify v1.10.0
 )
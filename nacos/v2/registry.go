@@ -16,12 +16,10 @@ package nacos
 
 import (
 	"fmt"
-	"net"
-	"strconv"
 
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
-	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
 	"github.com/nacos-group/nacos-sdk-go/v2/vo"
 )
@@ -61,17 +59,10 @@ func (n *nacosRegistry) Register(info *registry.Info) error {
 		return fmt.Errorf("valid parse registry info error: %w", err)
 	}
 
-	host, port, err := net.SplitHostPort(info.Addr.String())
+	host, p, err := address.AdvertiseHostPort(info.Addr, n.opts.addressOpts...)
 	if err != nil {
 		return fmt.Errorf("parse registry info addr error: %w", err)
 	}
-	p, err := strconv.Atoi(port)
-	if err != nil {
-		return fmt.Errorf("parse registry info port error: %w", err)
-	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
-	}
 	success, err := n.client.RegisterInstance(vo.RegisterInstanceParam{
 		Ip:          host,
 		Port:        uint64(p),
@@ -98,17 +89,10 @@ func (n *nacosRegistry) Deregister(info *registry.Info) error {
 	if err := n.validRegistryInfo(info); err != nil {
 		return fmt.Errorf("valid parse registry info error: %w", err)
 	}
-	host, port, err := net.SplitHostPort(info.Addr.String())
+	host, portInt, err := address.AdvertiseHostPort(info.Addr, n.opts.addressOpts...)
 	if err != nil {
 		return err
 	}
-	portInt, err := strconv.Atoi(port)
-	if err != nil {
-		return fmt.Errorf("parse registry info port error: %w", err)
-	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
-	}
 	success, err := n.client.DeregisterInstance(vo.DeregisterInstanceParam{
 		Ip:          host,
 		Port:        uint64(portInt),
@@ -126,6 +110,36 @@ func (n *nacosRegistry) Deregister(info *registry.Info) error {
 	return nil
 }
 
+// Drain disables the instance, so that it is no longer resolved while it is still registered.
+func (n *nacosRegistry) Drain(info *registry.Info) error {
+	if err := n.validRegistryInfo(info); err != nil {
+		return fmt.Errorf("valid parse registry info error: %w", err)
+	}
+	host, portInt, err := address.AdvertiseHostPort(info.Addr, n.opts.addressOpts...)
+	if err != nil {
+		return err
+	}
+	success, err := n.client.UpdateInstance(vo.UpdateInstanceParam{
+		Ip:          host,
+		Port:        uint64(portInt),
+		ServiceName: info.ServiceName,
+		GroupName:   n.opts.group,
+		ClusterName: n.opts.cluster,
+		Weight:      float64(info.Weight),
+		Enable:      false,
+		Ephemeral:   true,
+		Healthy:     true,
+		Metadata:    info.Tags,
+	})
+	if success {
+		hlog.SystemLogger().Info("drain instance success")
+	}
+	if err != nil {
+		return fmt.Errorf("drain instance error: %w", err)
+	}
+	return nil
+}
+
 func (n *nacosRegistry) validRegistryInfo(info *registry.Info) error {
 	if info == nil {
 		return fmt.Errorf("*registry.Info can not be empty")
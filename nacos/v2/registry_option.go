@@ -14,9 +14,12 @@
 
 package nacos
 
+import "github.com/hertz-contrib/registry/address"
+
 type registryOptions struct {
-	cluster string
-	group   string
+	cluster     string
+	group       string
+	addressOpts []address.Option
 }
 
 // RegistryOption Option is nacos registry option.
@@ -35,3 +38,10 @@ func WithRegistryGroup(group string) RegistryOption {
 		o.group = group
 	}
 }
+
+// WithRegistryAddressOptions with the options deciding the address to register, see the address package.
+func WithRegistryAddressOptions(opts ...address.Option) RegistryOption {
+	return func(o *registryOptions) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
@@ -17,7 +17,6 @@
 package polaris
 
 import (
-	"fmt"
 	"net"
 	"strconv"
 	"strings"
@@ -25,6 +24,7 @@ import (
 	"github.com/cloudwego/hertz/pkg/app/client/discovery"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/utils"
+	registryaddress "github.com/hertz-contrib/registry/address"
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/config"
 	"github.com/polarismesh/polaris-go/pkg/model"
@@ -80,25 +80,9 @@ func ChangePolarisInstanceToHertz(PolarisInstance model.Instance) discovery.Inst
 }
 
 // GetInfoHostAndPort gets Host and port from info.Addr.
-func GetInfoHostAndPort(Addr string) (string, int, error) {
-	infoHost, port, err := net.SplitHostPort(Addr)
-	if err != nil {
-		return "", 0, err
-	}
-	if port == "" {
-		return infoHost, 0, fmt.Errorf("registry info addr missing port")
-	}
-	if infoHost == "" || infoHost == "::" {
-		infoHost = utils.LocalIP()
-		if infoHost == utils.UNKNOWN_IP_ADDR {
-			return "", 0, fmt.Errorf("get local ip error")
-		}
-	}
-	infoPort, err := strconv.Atoi(port)
-	if err != nil {
-		return "", 0, err
-	}
-	return infoHost, infoPort, nil
+// The address to register is decided by the address package, configured by opts and its environment variables.
+func GetInfoHostAndPort(Addr string, opts ...registryaddress.Option) (string, int, error) {
+	return registryaddress.AdvertiseHostPort(utils.NewNetAddr("tcp", Addr), opts...)
 }
 
 // GetInstanceKey generates instanceKey for one instance.
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2024 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package polaris
+
+import (
+	registryaddress "github.com/hertz-contrib/registry/address"
+)
+
+type options struct {
+	configFile  string
+	addressOpts []registryaddress.Option
+}
+
+// Option is the option of polaris registry.
+type Option func(o *options)
+
+// WithConfigFile sets the polaris config file, the default config file of polaris-go is used if it is not set.
+func WithConfigFile(configFile string) Option {
+	return func(o *options) {
+		o.configFile = configFile
+	}
+}
+
+// WithAddressOptions sets how the registry decides the address to register, see the address package.
+func WithAddressOptions(opts ...registryaddress.Option) Option {
+	return func(o *options) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
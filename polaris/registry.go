@@ -25,6 +25,7 @@ import (
 
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
+	registryaddress "github.com/hertz-contrib/registry/address"
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/model"
 )
@@ -54,10 +55,29 @@ type polarisRegistry struct {
 	provider    api.ProviderAPI
 	lock        *sync.RWMutex
 	registryIns map[string]*polarisHeartbeat
+	addressOpts []registryaddress.Option
 }
 
 // NewPolarisRegistry creates a polaris based registry.
 func NewPolarisRegistry(configFile ...string) (Registry, error) {
+	var opts []Option
+	if len(configFile) != 0 {
+		opts = append(opts, WithConfigFile(configFile[0]))
+	}
+	return NewPolarisRegistryWithOptions(opts...)
+}
+
+// NewPolarisRegistryWithOptions creates a polaris based registry with options,
+// e.g. WithAddressOptions to decide the address to register.
+func NewPolarisRegistryWithOptions(opts ...Option) (Registry, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var configFile []string
+	if o.configFile != "" {
+		configFile = append(configFile, o.configFile)
+	}
 	sdkCtx, err := GetPolarisConfig(configFile...)
 	if err != nil {
 		return nil, err
@@ -68,6 +88,7 @@ func NewPolarisRegistry(configFile ...string) (Registry, error) {
 		provider:    api.NewProviderAPIByContext(sdkCtx),
 		registryIns: make(map[string]*polarisHeartbeat),
 		lock:        &sync.RWMutex{},
+		addressOpts: o.addressOpts,
 	}
 
 	return pRegistry, nil
@@ -78,7 +99,7 @@ func (svr *polarisRegistry) Register(info *registry.Info) error {
 	if err := validateInfo(info); err != nil {
 		return err
 	}
-	param, instanceKey, err := createRegisterParam(info)
+	param, instanceKey, err := createRegisterParam(info, svr.addressOpts...)
 	if err != nil {
 		return err
 	}
@@ -106,7 +127,7 @@ func (svr *polarisRegistry) Deregister(info *registry.Info) error {
 	if err := validateInfo(info); err != nil {
 		return err
 	}
-	request, instanceKey, err := createDeregisterParam(info)
+	request, instanceKey, err := createDeregisterParam(info, svr.addressOpts...)
 	if err != nil {
 		return err
 	}
@@ -178,8 +199,8 @@ func validateInfo(info *registry.Info) error {
 }
 
 // createRegisterParam convert registry.Info to polaris instance register request.
-func createRegisterParam(info *registry.Info) (*api.InstanceRegisterRequest, string, error) {
-	instanceHost, instancePort, err := GetInfoHostAndPort(info.Addr.String())
+func createRegisterParam(info *registry.Info, opts ...registryaddress.Option) (*api.InstanceRegisterRequest, string, error) {
+	instanceHost, instancePort, err := GetInfoHostAndPort(info.Addr.String(), opts...)
 	if err != nil {
 		return nil, "", err
 	}
@@ -209,8 +230,8 @@ func createRegisterParam(info *registry.Info) (*api.InstanceRegisterRequest, str
 }
 
 // createDeregisterParam convert registry.info to polaris instance deregister request.
-func createDeregisterParam(info *registry.Info) (*api.InstanceDeRegisterRequest, string, error) {
-	instanceHost, instancePort, err := GetInfoHostAndPort(info.Addr.String())
+func createDeregisterParam(info *registry.Info, opts ...registryaddress.Option) (*api.InstanceDeRegisterRequest, string, error) {
+	instanceHost, instancePort, err := GetInfoHostAndPort(info.Addr.String(), opts...)
 	if err != nil {
 		return nil, "", err
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	registryaddress "github.com/hertz-contrib/registry/address"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -98,3 +99,21 @@ func TestEmptyEndpoints(t *testing.T) {
 	_, err := NewPolarisResolver()
 	assert.Nil(t, err)
 }
+
+func TestCreateRegisterParamWithAddressOptions(t *testing.T) {
+	info := &registry.Info{
+		ServiceName: serviceName,
+		Addr:        utils.NewNetAddr("tcp", "0.0.0.0:8888"),
+		Weight:      10,
+	}
+	param, instanceKey, err := createRegisterParam(info, registryaddress.WithAdvertiseAddr("10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", param.Host)
+	assert.Equal(t, 8888, param.Port)
+	assert.Equal(t, GetInstanceKey(namespace, serviceName, "10.0.0.1", "8888"), instanceKey)
+
+	deregisterParam, _, err := createDeregisterParam(info, registryaddress.WithAdvertiseAddr("10.0.0.1:9999"))
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", deregisterParam.Host)
+	assert.Equal(t, 9999, deregisterParam.Port)
+}
@@ -16,17 +16,25 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/hertz-contrib/registry/address"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
-	hertz  = "hertz"
-	server = "server"
-	tcp    = "tcp"
+	hertz     = "hertz"
+	server    = "server"
+	heartbeat = "heartbeat"
+	event     = "event"
+	tcp       = "tcp"
+
+	statusDraining = "draining"
 )
 
 const (
@@ -39,6 +47,24 @@ type registryHash struct {
 	key   string
 	field string
 	value string
+	// heartbeatKey is the sorted set holding the expiration time of every instance
+	heartbeatKey string
+	// channel is where the changes of the instances are published
+	channel string
+}
+
+const (
+	eventPut = "put"
+	eventDel = "del"
+)
+
+// registryEvent is published by the registry when an instance is put or deleted.
+type registryEvent struct {
+	Type  string `json:"type"`
+	Field string `json:"field"`
+	Value string `json:"value,omitempty"`
+	// TTL is the number of seconds before the instance expires, negative means never
+	TTL int `json:"ttl,omitempty"`
 }
 
 type registryInfo struct {
@@ -46,6 +72,8 @@ type registryInfo struct {
 	Addr        string            `json:"addr"`
 	Weight      int               `json:"weight"`
 	Tags        map[string]string `json:"tags"`
+	// Status is statusDraining when the instance is drained, and empty when it is serving.
+	Status string `json:"status,omitempty"`
 }
 
 func validateRegistryInfo(info *registry.Info) error {
@@ -65,36 +93,66 @@ func generateKey(serviceName, serviceType string) string {
 	return fmt.Sprintf("/%s/%s/%s", hertz, serviceName, serviceType)
 }
 
-func prepareRegistryHash(info *registry.Info) (*registryHash, error) {
-	meta, err := sonic.Marshal(convertInfo(info))
+func prepareRegistryHash(info *registry.Info, opts ...address.Option) (*registryHash, error) {
+	addr, err := address.Advertise(info.Addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := sonic.Marshal(convertInfo(info, addr))
 	if err != nil {
 		return nil, err
 	}
 	return &registryHash{
-		key:   generateKey(info.ServiceName, server),
-		field: info.Addr.String(),
-		value: string(meta),
+		key:          generateKey(info.ServiceName, server),
+		field:        addr,
+		value:        string(meta),
+		heartbeatKey: generateKey(info.ServiceName, heartbeat),
+		channel:      generateKey(info.ServiceName, event),
 	}, nil
 }
 
-func convertInfo(info *registry.Info) *registryInfo {
+func convertInfo(info *registry.Info, addr string) *registryInfo {
 	return &registryInfo{
 		ServiceName: info.ServiceName,
-		Addr:        info.Addr.String(),
+		Addr:        addr,
 		Weight:      info.Weight,
 		Tags:        info.Tags,
 	}
 }
 
+// keepAlive refreshes the expiration time of the instance until ctx is done.
 func keepAlive(ctx context.Context, hash *registryHash, r *redisRegistry) {
 	ticker := time.NewTicker(time.Duration(r.options.refreshInterval) * time.Second)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			r.client.Expire(ctx, hash.key, time.Duration(r.options.expireTime)*time.Second)
+			// the value of hash is changed by Drain
+			r.mu.Lock()
+			args := hash.registerArgs(r.options.expireTime)
+			r.mu.Unlock()
+			err := registerScript.Run(ctx, r.client, hash.keys(), args).Err()
+			if err != nil && !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				hlog.Warnf("HERTZ: refresh instance %s of %s failed with err: %v", hash.field, hash.key, err)
+			}
 		case <-ctx.Done():
-			break
+			return
 		}
 	}
 }
+
+func (h *registryHash) instanceKey() string {
+	return h.key + "/" + h.field
+}
+
+func (h *registryHash) keys() []string {
+	return []string{h.key, h.heartbeatKey}
+}
+
+func (h *registryHash) registerArgs(expireTime int) []interface{} {
+	return []interface{}{h.field, h.value, expireTime, h.channel}
+}
+
+func (h *registryHash) deregisterArgs() []interface{} {
+	return []interface{}{h.field, h.channel}
+}
@@ -12,5 +12,6 @@ require (
 
 require (
 	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/hertz-contrib/registry/address v0.1.0
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -20,6 +20,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/hertz-contrib/registry/address"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -29,6 +30,8 @@ type Options struct {
 	*redis.Options
 	expireTime      int
 	refreshInterval int
+	subscribe       bool
+	addressOpts     []address.Option
 }
 
 // WithExpireTime redis key expiration time in seconds
@@ -49,6 +52,22 @@ func WithRefreshInterval(interval int) Option {
 	}
 }
 
+// WithSubscribe makes the resolver subscribe to the change events of the resolved services
+// and serve Resolve from an in-memory instance map
+// Default: false
+func WithSubscribe() Option {
+	return func(opts *Options) {
+		opts.subscribe = true
+	}
+}
+
+// WithAddressOptions sets how the registry decides the address to register, see the address package
+func WithAddressOptions(addressOpts ...address.Option) Option {
+	return func(opts *Options) {
+		opts.addressOpts = append(opts.addressOpts, addressOpts...)
+	}
+}
+
 func WithPassword(password string) Option {
 	return func(opts *Options) {
 		opts.Password = password
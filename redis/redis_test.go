@@ -193,6 +193,145 @@ func TestResolve(t *testing.T) {
 	}
 }
 
+// TestStaleInstance Test that an instance which stops refreshing is ignored while its siblings stay alive.
+func TestStaleInstance(t *testing.T) {
+	defer redisCli.FlushDB(ctx)
+	srvName := "hertz.test.stale"
+	alive := &registry.Info{
+		ServiceName: srvName,
+		Addr:        utils.NewNetAddr(tcp, "127.0.0.1:9000"),
+		Weight:      10,
+	}
+	crashed := &registry.Info{
+		ServiceName: srvName,
+		Addr:        utils.NewNetAddr(tcp, "127.0.0.1:9001"),
+		Weight:      10,
+	}
+	r := NewRedisRegistry("127.0.0.1:6379")
+	assert.Nil(t, r.Register(alive))
+	assert.Nil(t, r.Register(crashed))
+
+	resolver := NewRedisResolver("127.0.0.1:6379")
+	res, err := resolver.Resolve(ctx, srvName)
+	assert.Nil(t, err)
+	assert.Len(t, res.Instances, 2)
+
+	// simulate a crash: stop refreshing and let the expiration time pass
+	hash, err := prepareRegistryHash(crashed)
+	assert.Nil(t, err)
+	rr := r.(*redisRegistry)
+	rr.mu.Lock()
+	rr.rctxs[hash.instanceKey()].cancel()
+	rr.mu.Unlock()
+	redisCli.ZAdd(ctx, hash.heartbeatKey, redis.Z{Score: float64(time.Now().Unix() - 1), Member: hash.field})
+
+	res, err = resolver.Resolve(ctx, srvName)
+	assert.Nil(t, err)
+	if assert.Len(t, res.Instances, 1) {
+		assert.Equal(t, alive.Addr.String(), res.Instances[0].Address().String())
+	}
+
+	// the refresh of the alive instance removes the expired one
+	assert.Nil(t, r.Register(alive))
+	assert.False(t, redisCli.HExists(ctx, hash.key, hash.field).Val())
+	assert.Equal(t, int64(1), redisCli.ZCard(ctx, hash.heartbeatKey).Val())
+
+	assert.Nil(t, r.Deregister(alive))
+	res, err = resolver.Resolve(ctx, srvName)
+	assert.Nil(t, err)
+	assert.Len(t, res.Instances, 0)
+}
+
+// TestSubscribeResolve Test the resolver serving Resolve from the events of the registry.
+func TestSubscribeResolve(t *testing.T) {
+	defer redisCli.FlushDB(ctx)
+	srvName := "hertz.test.subscribe"
+	info := &registry.Info{
+		ServiceName: srvName,
+		Addr:        utils.NewNetAddr(tcp, "127.0.0.1:9000"),
+		Weight:      15,
+		Tags:        map[string]string{"hello": "world"},
+	}
+	r := NewRedisRegistry("127.0.0.1:6379")
+	resolver := NewRedisResolver("127.0.0.1:6379", WithSubscribe())
+
+	// the first resolve subscribes to the events of the service
+	res, err := resolver.Resolve(ctx, srvName)
+	assert.Nil(t, err)
+	assert.Len(t, res.Instances, 0)
+
+	assert.Nil(t, r.Register(info))
+	assert.Eventually(t, func() bool {
+		res, err = resolver.Resolve(ctx, srvName)
+		return err == nil && len(res.Instances) == 1
+	}, time.Second*3, time.Millisecond*100)
+	assert.Equal(t, info.Addr.String(), res.Instances[0].Address().String())
+	assert.Equal(t, info.Weight, res.Instances[0].Weight())
+	tag, ok := res.Instances[0].Tag("hello")
+	assert.True(t, ok)
+	assert.Equal(t, "world", tag)
+
+	assert.Nil(t, r.Deregister(info))
+	assert.Eventually(t, func() bool {
+		res, err = resolver.Resolve(ctx, srvName)
+		return err == nil && len(res.Instances) == 0
+	}, time.Second*3, time.Millisecond*100)
+}
+
+func TestSubscribeResolveUnreachable(t *testing.T) {
+	r := NewRedisResolver("127.0.0.1:1", WithSubscribe()).(*redisResolver)
+	r.readyTimeout = 100 * time.Millisecond
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Resolve(context.Background(), "hertz.test.unreachable")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("resolve blocks while redis is unreachable")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	defer redisCli.FlushDB(ctx)
+	srvName := "hertz.test.drain"
+	info := &registry.Info{
+		ServiceName: srvName,
+		Addr:        utils.NewNetAddr(tcp, "127.0.0.1:9000"),
+		Weight:      10,
+	}
+	r := NewRedisRegistry("127.0.0.1:6379")
+	drainer := r.(interface{ Drain(*registry.Info) error })
+	resolver := NewRedisResolver("127.0.0.1:6379")
+	subscriber := NewRedisResolver("127.0.0.1:6379", WithSubscribe())
+
+	// only registered instances can be drained
+	assert.NotNil(t, drainer.Drain(info))
+
+	assert.Nil(t, r.Register(info))
+	res, err := subscriber.Resolve(ctx, srvName)
+	assert.Nil(t, err)
+	assert.Len(t, res.Instances, 1)
+
+	assert.Nil(t, drainer.Drain(info))
+	res, err = resolver.Resolve(ctx, srvName)
+	assert.Nil(t, err)
+	assert.Len(t, res.Instances, 0)
+	assert.Eventually(t, func() bool {
+		res, err = subscriber.Resolve(ctx, srvName)
+		return err == nil && len(res.Instances) == 0
+	}, time.Second*3, time.Millisecond*100)
+
+	// the drained instance is still registered
+	n, err := redisCli.HLen(ctx, generateKey(srvName, server)).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	assert.Nil(t, r.Deregister(info))
+}
+
 // TestRedisRegistryWithHertz Test redis registry complete workflow (service registry|service de-registry|service resolver) with hertz.
 func TestRedisRegistryWithHertz(t *testing.T) {
 	addr := "127.0.0.1:8080"
@@ -17,9 +17,11 @@ package redis
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/redis/go-redis/v9"
 )
@@ -30,12 +32,14 @@ type redisRegistry struct {
 	mu      sync.Mutex
 	options *Options
 	client  *redis.Client
-	rctx    *registryContext
+	// rctxs holds the keepalive context of every registered instance
+	rctxs map[string]*registryContext
 }
 
 type registryContext struct {
 	ctx    context.Context
 	cancel context.CancelFunc
+	hash   *registryHash
 }
 
 // NewRedisRegistry creates a redis registry
@@ -56,6 +60,7 @@ func NewRedisRegistry(addr string, opts ...Option) registry.Registry {
 	return &redisRegistry{
 		options: options,
 		client:  rdb,
+		rctxs:   make(map[string]*registryContext),
 	}
 }
 
@@ -68,26 +73,30 @@ func (r *redisRegistry) Register(info *registry.Info) error {
 	rctx.ctx, rctx.cancel = context.WithCancel(context.Background())
 	rdb := r.client
 
-	hash, err := prepareRegistryHash(info)
+	hash, err := prepareRegistryHash(info, r.options.addressOpts...)
 	if err != nil {
+		rctx.cancel()
 		return err
 	}
+	rctx.hash = hash
 
 	r.mu.Lock()
-	r.rctx = &rctx
-	r.mu.Unlock()
-
-	keys := []string{
-		hash.key,
-	}
-	args := []interface{}{
-		hash.field,
-		hash.value,
-		r.options.expireTime,
+	// registering the same instance again replaces its keepalive
+	if prev, ok := r.rctxs[hash.instanceKey()]; ok {
+		prev.cancel()
 	}
+	r.rctxs[hash.instanceKey()] = &rctx
+	r.mu.Unlock()
 
-	err = registerScript.Run(rctx.ctx, rdb, keys, args).Err()
+	err = registerScript.Run(rctx.ctx, rdb, hash.keys(), hash.registerArgs(r.options.expireTime)).Err()
 	if err != nil && !errors.Is(err, redis.Nil) {
+		// the instance is not registered, so that Drain and Deregister must not find it
+		r.mu.Lock()
+		if r.rctxs[hash.instanceKey()] == &rctx {
+			delete(r.rctxs, hash.instanceKey())
+		}
+		r.mu.Unlock()
+		rctx.cancel()
 		return err
 	}
 
@@ -102,43 +111,94 @@ func (r *redisRegistry) Deregister(info *registry.Info) error {
 		return err
 	}
 
-	rctx := r.rctx
 	rdb := r.client
 
-	hash, err := prepareRegistryHash(info)
+	hash, err := prepareRegistryHash(info, r.options.addressOpts...)
 	if err != nil {
 		return err
 	}
 
-	keys := []string{
-		hash.key,
-	}
-	args := []interface{}{
-		hash.field,
+	r.mu.Lock()
+	if rctx, ok := r.rctxs[hash.instanceKey()]; ok {
+		rctx.cancel()
+		delete(r.rctxs, hash.instanceKey())
 	}
+	r.mu.Unlock()
 
-	err = deregisterScript.Run(rctx.ctx, rdb, keys, args).Err()
+	err = deregisterScript.Run(context.Background(), rdb, hash.keys(), hash.deregisterArgs()).Err()
 	if err != nil && !errors.Is(err, redis.Nil) {
 		return err
 	}
+	return nil
+}
+
+// Drain marks a registered instance as draining, so that it is no longer resolved while it is still registered.
+func (r *redisRegistry) Drain(info *registry.Info) error {
+	if err := validateRegistryInfo(info); err != nil {
+		return err
+	}
+
+	hash, err := prepareRegistryHash(info, r.options.addressOpts...)
+	if err != nil {
+		return err
+	}
+	ri := convertInfo(info, hash.field)
+	ri.Status = statusDraining
+	meta, err := sonic.Marshal(ri)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	rctx, ok := r.rctxs[hash.instanceKey()]
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("instance %s of %s has not registered", hash.field, info.ServiceName)
+	}
+	// keepAlive puts the value of hash again, which must not make the instance serving again
+	rctx.hash.value = string(meta)
+	args := rctx.hash.registerArgs(r.options.expireTime)
+	r.mu.Unlock()
 
-	rctx.cancel()
+	err = registerScript.Run(rctx.ctx, r.client, rctx.hash.keys(), args).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
 	return nil
 }
 
+// registerScript sets the instance and its expiration time, removes the expired
+// instances of the same service, and publishes the changes.
 var registerScript = redis.NewScript(`
 local key = KEYS[1]
+local heartbeatKey = KEYS[2]
 local field = ARGV[1]
 local value = ARGV[2]
 local expireTime = tonumber(ARGV[3])
+local channel = ARGV[4]
+local now = tonumber(redis.call('TIME')[1])
+
+local expired = redis.call('ZRANGEBYSCORE', heartbeatKey, '-inf', now)
+for _, f in ipairs(expired) do
+	redis.call('HDEL', key, f)
+	redis.call('ZREM', heartbeatKey, f)
+	redis.call('PUBLISH', channel, cjson.encode({type = 'del', field = f}))
+end
 
 redis.call('HSET', key, field, value)
+redis.call('ZADD', heartbeatKey, now + expireTime, field)
 redis.call('EXPIRE', key, expireTime)
+redis.call('EXPIRE', heartbeatKey, expireTime)
+redis.call('PUBLISH', channel, cjson.encode({type = 'put', field = field, value = value, ttl = expireTime}))
 `)
 
 var deregisterScript = redis.NewScript(`
 local key = KEYS[1]
+local heartbeatKey = KEYS[2]
 local field = ARGV[1]
+local channel = ARGV[2]
 
 redis.call('HDEL', key, field)
+redis.call('ZREM', heartbeatKey, field)
+redis.call('PUBLISH', channel, cjson.encode({type = 'del', field = field}))
 `)
@@ -16,7 +16,13 @@ package redis
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/client/discovery"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
@@ -26,7 +32,32 @@ import (
 var _ discovery.Resolver = (*redisResolver)(nil)
 
 type redisResolver struct {
-	client *redis.Client
+	client    *redis.Client
+	subscribe bool
+	// readyTimeout bounds the wait for the first subscription of a service
+	readyTimeout time.Duration
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	// caches holds the instance map of every subscribed service, keyed by channel
+	caches map[string]*serviceCache
+}
+
+// serviceCache is the in-memory instance map of one service, kept up to date by its channel.
+type serviceCache struct {
+	desc  string
+	ready chan struct{}
+
+	mu        sync.RWMutex
+	synced    bool
+	err       error
+	instances map[string]*cachedInstance
+}
+
+type cachedInstance struct {
+	info registryInfo
+	// deadline is when the instance expires, zero means never
+	deadline time.Time
 }
 
 // NewRedisResolver creates a redis resolver
@@ -41,8 +72,26 @@ func NewRedisResolver(addr string, opts ...Option) discovery.Resolver {
 	}
 	rdb := redis.NewClient(options.Options)
 	return &redisResolver{
-		client: rdb,
+		client:       rdb,
+		subscribe:    options.subscribe,
+		readyTimeout: readyTimeout(options.Options),
+		caches:       make(map[string]*serviceCache),
+	}
+}
+
+// readyTimeout returns the time to dial redis and read the subscription confirmation.
+func readyTimeout(o *redis.Options) time.Duration {
+	timeout := o.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	switch {
+	case o.ReadTimeout > 0:
+		timeout += o.ReadTimeout
+	case o.ReadTimeout == 0:
+		timeout += 3 * time.Second
 	}
+	return timeout
 }
 
 func (r *redisResolver) Target(_ context.Context, target *discovery.TargetInfo) string {
@@ -50,21 +99,21 @@ func (r *redisResolver) Target(_ context.Context, target *discovery.TargetInfo)
 }
 
 func (r *redisResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
-	rdb := r.client
-	fvs := rdb.HGetAll(ctx, generateKey(desc, server)).Val()
+	if r.subscribe {
+		return r.resolveFromCache(ctx, desc)
+	}
+	return r.resolveDirectly(ctx, desc)
+}
+
+// resolveDirectly reads the instances of the service desc from redis.
+func (r *redisResolver) resolveDirectly(ctx context.Context, desc string) (discovery.Result, error) {
+	instances, err := r.fetch(ctx, desc)
+	if err != nil {
+		return discovery.Result{}, err
+	}
 	var its []discovery.Instance
-	for f, v := range fvs {
-		var ri registryInfo
-		err := sonic.Unmarshal([]byte(v), &ri)
-		if err != nil {
-			hlog.Warnf("HERTZ: fail to unmarshal with err: %v, ignore instance Addr: %v", err, f)
-			continue
-		}
-		weight := ri.Weight
-		if weight <= 0 {
-			weight = defaultWeight
-		}
-		its = append(its, discovery.NewInstance(tcp, ri.Addr, weight, ri.Tags))
+	for _, ins := range instances {
+		its = append(its, ins.toInstance())
 	}
 	return discovery.Result{
 		CacheKey:  desc,
@@ -75,3 +124,235 @@ func (r *redisResolver) Resolve(ctx context.Context, desc string) (discovery.Res
 func (r *redisResolver) Name() string {
 	return "redis"
 }
+
+// fetch reads the instances of the service desc which are not expired.
+func (r *redisResolver) fetch(ctx context.Context, desc string) (map[string]*cachedInstance, error) {
+	keys := []string{generateKey(desc, server), generateKey(desc, heartbeat)}
+	res, err := resolveScript.Run(ctx, r.client, keys).StringSlice()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	now := time.Now()
+	instances := make(map[string]*cachedInstance, len(res)/3)
+	for i := 0; i+2 < len(res); i += 3 {
+		ttl, _ := strconv.Atoi(res[i+2])
+		if ins, ok := newCachedInstance(res[i], res[i+1], ttl, now); ok && !ins.draining() {
+			instances[res[i]] = ins
+		}
+	}
+	return instances, nil
+}
+
+func (r *redisResolver) resolveFromCache(ctx context.Context, desc string) (discovery.Result, error) {
+	c := r.getCache(ctx, desc)
+	timer := time.NewTimer(r.readyTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		return discovery.Result{}, ctx.Err()
+	case <-timer.C:
+		// the subscription is not confirmed in time, e.g. redis is unreachable,
+		// which must not block the requests whose ctx has no deadline
+		return r.resolveDirectly(ctx, desc)
+	}
+
+	c.mu.RLock()
+	synced, err := c.synced, c.err
+	c.mu.RUnlock()
+	if !synced {
+		// the sync triggered by the subscription failed, try again
+		if err = r.sync(ctx, c); err != nil {
+			return discovery.Result{}, err
+		}
+	}
+	return c.result(), nil
+}
+
+// getCache returns the cache of desc, subscribing to its channel if needed.
+func (r *redisResolver) getCache(ctx context.Context, desc string) *serviceCache {
+	channel := generateKey(desc, event)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.caches[channel]; ok {
+		return c
+	}
+
+	c := &serviceCache{
+		desc:  desc,
+		ready: make(chan struct{}),
+	}
+	r.caches[channel] = c
+	if r.pubsub == nil {
+		r.pubsub = r.client.Subscribe(ctx)
+		ch := r.pubsub.ChannelWithSubscriptions()
+		gopool.Go(func() {
+			r.receive(ch)
+		})
+	}
+	if err := r.pubsub.Subscribe(ctx, channel); err != nil {
+		// the subscription is retried when the pubsub reconnects
+		hlog.Warnf("HERTZ: subscribe %s failed with err: %v", channel, err)
+	}
+	return c
+}
+
+// receive applies the events of the subscribed channels in order.
+// Every (re)subscription of a channel triggers a full sync of its service,
+// so that the events missed while disconnected are not lost.
+func (r *redisResolver) receive(ch <-chan interface{}) {
+	for msg := range ch {
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind != "subscribe" {
+				continue
+			}
+			c := r.lookupCache(m.Channel)
+			if c == nil {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			if err := r.sync(ctx, c); err != nil {
+				hlog.Warnf("HERTZ: sync instances of %s failed with err: %v", c.desc, err)
+			}
+			cancel()
+			c.markReady()
+		case *redis.Message:
+			c := r.lookupCache(m.Channel)
+			if c == nil {
+				continue
+			}
+			var ev registryEvent
+			if err := sonic.UnmarshalString(m.Payload, &ev); err != nil {
+				hlog.Warnf("HERTZ: fail to unmarshal event with err: %v, ignore event: %v", err, m.Payload)
+				continue
+			}
+			c.apply(&ev)
+		}
+	}
+}
+
+func (r *redisResolver) lookupCache(channel string) *serviceCache {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.caches[channel]
+}
+
+// sync replaces the instance map of c with the instances in redis.
+func (r *redisResolver) sync(ctx context.Context, c *serviceCache) error {
+	instances, err := r.fetch(ctx, c.desc)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err != nil {
+		return err
+	}
+	c.instances = instances
+	c.synced = true
+	return nil
+}
+
+func (c *serviceCache) markReady() {
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+}
+
+func (c *serviceCache) apply(ev *registryEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		// the event is included in the coming sync
+		return
+	}
+	switch ev.Type {
+	case eventPut:
+		ins, ok := newCachedInstance(ev.Field, ev.Value, ev.TTL, time.Now())
+		if !ok {
+			return
+		}
+		if ins.draining() {
+			delete(c.instances, ev.Field)
+		} else {
+			c.instances[ev.Field] = ins
+		}
+	case eventDel:
+		delete(c.instances, ev.Field)
+	}
+}
+
+func (c *serviceCache) result() discovery.Result {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	fields := make([]string, 0, len(c.instances))
+	for f := range c.instances {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	now := time.Now()
+	var its []discovery.Instance
+	for _, f := range fields {
+		ins := c.instances[f]
+		if !ins.deadline.IsZero() && !ins.deadline.After(now) {
+			continue
+		}
+		its = append(its, ins.toInstance())
+	}
+	return discovery.Result{
+		CacheKey:  c.desc,
+		Instances: its,
+	}
+}
+
+func newCachedInstance(field, value string, ttl int, now time.Time) (*cachedInstance, bool) {
+	var ri registryInfo
+	err := sonic.UnmarshalString(value, &ri)
+	if err != nil {
+		hlog.Warnf("HERTZ: fail to unmarshal with err: %v, ignore instance Addr: %v", err, field)
+		return nil, false
+	}
+	ins := &cachedInstance{info: ri}
+	if ttl > 0 {
+		ins.deadline = now.Add(time.Duration(ttl) * time.Second)
+	}
+	return ins, true
+}
+
+func (ins *cachedInstance) draining() bool {
+	return ins.info.Status == statusDraining
+}
+
+func (ins *cachedInstance) toInstance() discovery.Instance {
+	weight := ins.info.Weight
+	if weight <= 0 {
+		weight = defaultWeight
+	}
+	return discovery.NewInstance(tcp, ins.info.Addr, weight, ins.info.Tags)
+}
+
+// resolveScript returns the field, value and remaining seconds to live of the instances
+// which are not expired. Instances without an expiration time are kept with a negative
+// ttl, they are registered by older versions and rely on the expiration of the whole key.
+var resolveScript = redis.NewScript(`
+local key = KEYS[1]
+local heartbeatKey = KEYS[2]
+local now = tonumber(redis.call('TIME')[1])
+
+local fvs = redis.call('HGETALL', key)
+local alive = {}
+for i = 1, #fvs, 2 do
+	local expireAt = redis.call('ZSCORE', heartbeatKey, fvs[i])
+	local ttl = -1
+	if expireAt then
+		ttl = tonumber(expireAt) - now
+	end
+	if not expireAt or ttl > 0 then
+		table.insert(alive, fvs[i])
+		table.insert(alive, fvs[i + 1])
+		table.insert(alive, tostring(ttl))
+	end
+end
+return alive
+`)
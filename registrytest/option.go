@@ -0,0 +1,73 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registrytest
+
+import "time"
+
+const (
+	defaultTimeout           = 5 * time.Second
+	defaultInterval          = 50 * time.Millisecond
+	defaultServiceNamePrefix = "hertz.registrytest"
+)
+
+type options struct {
+	timeout           time.Duration
+	interval          time.Duration
+	serviceNamePrefix string
+	skips             map[string]bool
+}
+
+// Option is the option of the conformance tests.
+type Option func(o *options)
+
+// WithTimeout sets how long a change may take to be resolved, for the backends which are eventually consistent.
+// Default: 5s
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithInterval sets the interval of resolving while waiting for a change.
+// Default: 50ms
+func WithInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithServiceNamePrefix sets the prefix of the service names used by the tests,
+// each test uses a unique service name starting with it.
+// Default: hertz.registrytest
+func WithServiceNamePrefix(prefix string) Option {
+	return func(o *options) {
+		o.serviceNamePrefix = prefix
+	}
+}
+
+// WithSkip skips the tests named cases, for the known deviations of a backend.
+func WithSkip(cases ...string) Option {
+	return func(o *options) {
+		for _, c := range cases {
+			o.skips[c] = true
+		}
+	}
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
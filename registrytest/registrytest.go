@@ -0,0 +1,298 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registrytest provides the conformance tests of a registry and its resolver,
+// so that every backend behaves the same for the servers and clients of Hertz.
+package registrytest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The names of the tests, which can be skipped by WithSkip.
+const (
+	CaseRoundTrip              = "RoundTrip"
+	CaseTagsAndWeight          = "TagsAndWeight"
+	CaseWildcardHost           = "WildcardHost"
+	CaseDuplicateRegistration  = "DuplicateRegistration"
+	CaseDeregisterUnknown      = "DeregisterUnknown"
+	CaseConcurrentRegistration = "ConcurrentRegistration"
+	CaseInvalidInfo            = "InvalidInfo"
+)
+
+const basePort = 18000
+
+// Backend is a registry and the resolver which discovers the instances registered by it.
+type Backend struct {
+	Registry registry.Registry
+	Resolver discovery.Resolver
+}
+
+// NewBackend creates the backend used by a test, it may release the backend with t.Cleanup.
+type NewBackend func(t *testing.T) Backend
+
+type suite struct {
+	newBackend NewBackend
+	opts       options
+}
+
+// Run runs the conformance tests against the backends created by newBackend, each test in a subtest of t:
+//
+//   - RoundTrip: a registered instance is resolved, and is not resolved after it is deregistered.
+//   - TagsAndWeight: the tags and the weight of an instance are resolved as registered.
+//   - WildcardHost: an instance registered on 0.0.0.0 or :: is resolved with a specified host and the same port.
+//   - DuplicateRegistration: registering an instance again succeeds and updates it.
+//   - DeregisterUnknown: deregistering an unknown instance succeeds and does not affect the other instances.
+//   - ConcurrentRegistration: the instances registered and deregistered concurrently are all applied.
+//   - InvalidInfo: registering an info without service name or address fails.
+func Run(t *testing.T, newBackend NewBackend, opts ...Option) {
+	o := options{
+		timeout:           defaultTimeout,
+		interval:          defaultInterval,
+		serviceNamePrefix: defaultServiceNamePrefix,
+		skips:             make(map[string]bool),
+	}
+	o.apply(opts...)
+	s := &suite{newBackend: newBackend, opts: o}
+
+	for _, c := range []struct {
+		name string
+		fn   func(t *testing.T, b Backend, service string)
+	}{
+		{CaseRoundTrip, s.testRoundTrip},
+		{CaseTagsAndWeight, s.testTagsAndWeight},
+		{CaseWildcardHost, s.testWildcardHost},
+		{CaseDuplicateRegistration, s.testDuplicateRegistration},
+		{CaseDeregisterUnknown, s.testDeregisterUnknown},
+		{CaseConcurrentRegistration, s.testConcurrentRegistration},
+		{CaseInvalidInfo, s.testInvalidInfo},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if o.skips[c.name] {
+				t.Skipf("%s is skipped", c.name)
+			}
+			service := fmt.Sprintf("%s.%s.%d", o.serviceNamePrefix, strings.ToLower(c.name), time.Now().UnixNano())
+			c.fn(t, newBackend(t), service)
+		})
+	}
+}
+
+func newInfo(service string, port int) *registry.Info {
+	return &registry.Info{
+		ServiceName: service,
+		Addr:        utils.NewNetAddr("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port))),
+		Weight:      registry.DefaultWeight,
+	}
+}
+
+func (s *suite) resolve(b Backend, service string) []discovery.Instance {
+	ctx := context.Background()
+	desc := b.Resolver.Target(ctx, &discovery.TargetInfo{Host: service, Tags: map[string]string{}})
+	res, err := b.Resolver.Resolve(ctx, desc)
+	if err != nil {
+		// some backends fail to resolve a service without instance
+		return nil
+	}
+	return res.Instances
+}
+
+// waitFor resolves service until cond holds for its instances, and fails t after the timeout.
+func (s *suite) waitFor(t *testing.T, b Backend, service, msg string, cond func(instances []discovery.Instance) bool) []discovery.Instance {
+	deadline := time.Now().Add(s.opts.timeout)
+	for {
+		instances := s.resolve(b, service)
+		if cond(instances) {
+			return instances
+		}
+		if time.Now().After(deadline) {
+			require.FailNow(t, msg, "resolved instances: %v", addresses(instances))
+		}
+		time.Sleep(s.opts.interval)
+	}
+}
+
+// waitForAddrs waits until the resolved addresses of service are addrs.
+func (s *suite) waitForAddrs(t *testing.T, b Backend, service string, addrs ...string) []discovery.Instance {
+	sort.Strings(addrs)
+	msg := fmt.Sprintf("%s is not resolved to %v", service, addrs)
+	return s.waitFor(t, b, service, msg, func(instances []discovery.Instance) bool {
+		got := addresses(instances)
+		return len(got) == len(addrs) && (len(got) == 0 || assert.ObjectsAreEqual(addrs, got))
+	})
+}
+
+func addresses(instances []discovery.Instance) []string {
+	addrs := make([]string, 0, len(instances))
+	for _, ins := range instances {
+		addrs = append(addrs, ins.Address().String())
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func find(instances []discovery.Instance, addr string) discovery.Instance {
+	for _, ins := range instances {
+		if ins.Address().String() == addr {
+			return ins
+		}
+	}
+	return nil
+}
+
+func (s *suite) testRoundTrip(t *testing.T, b Backend, service string) {
+	info := newInfo(service, basePort)
+	require.Nil(t, b.Registry.Register(info))
+	instances := s.waitForAddrs(t, b, service, info.Addr.String())
+	assert.Equal(t, "tcp", instances[0].Address().Network())
+
+	require.Nil(t, b.Registry.Deregister(info))
+	s.waitForAddrs(t, b, service)
+}
+
+func (s *suite) testTagsAndWeight(t *testing.T, b Backend, service string) {
+	info := newInfo(service, basePort)
+	info.Weight = 20
+	info.Tags = map[string]string{"key1": "value1", "key2": "value2"}
+	require.Nil(t, b.Registry.Register(info))
+	t.Cleanup(func() { _ = b.Registry.Deregister(info) })
+
+	ins := s.waitForAddrs(t, b, service, info.Addr.String())[0]
+	assert.Equal(t, 20, ins.Weight())
+	for k, v := range info.Tags {
+		value, ok := ins.Tag(k)
+		assert.True(t, ok, "tag %s is not resolved", k)
+		assert.Equal(t, v, value)
+	}
+}
+
+func (s *suite) testWildcardHost(t *testing.T, b Backend, service string) {
+	for i, host := range []string{"0.0.0.0", "::"} {
+		port := strconv.Itoa(basePort + i)
+		info := &registry.Info{
+			ServiceName: service,
+			Addr:        utils.NewNetAddr("tcp", net.JoinHostPort(host, port)),
+			Weight:      registry.DefaultWeight,
+		}
+		require.Nil(t, b.Registry.Register(info))
+
+		msg := fmt.Sprintf("%s registered on %s is not resolved", service, info.Addr.String())
+		instances := s.waitFor(t, b, service, msg, func(instances []discovery.Instance) bool {
+			for _, ins := range instances {
+				if _, p, err := net.SplitHostPort(ins.Address().String()); err == nil && p == port {
+					return true
+				}
+			}
+			return false
+		})
+		for _, ins := range instances {
+			h, p, err := net.SplitHostPort(ins.Address().String())
+			require.Nil(t, err)
+			if p != port {
+				continue
+			}
+			ip := net.ParseIP(h)
+			assert.False(t, h == "" || (ip != nil && ip.IsUnspecified()), "%s is registered with the unspecified host %s", info.Addr.String(), h)
+		}
+
+		require.Nil(t, b.Registry.Deregister(info))
+		s.waitFor(t, b, service, fmt.Sprintf("%s is not deregistered", info.Addr.String()), func(instances []discovery.Instance) bool {
+			for _, ins := range instances {
+				if _, p, err := net.SplitHostPort(ins.Address().String()); err == nil && p == port {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (s *suite) testDuplicateRegistration(t *testing.T, b Backend, service string) {
+	info := newInfo(service, basePort)
+	require.Nil(t, b.Registry.Register(info))
+	s.waitForAddrs(t, b, service, info.Addr.String())
+
+	updated := newInfo(service, basePort)
+	updated.Weight = 30
+	require.Nil(t, b.Registry.Register(updated), "registering an instance again should succeed")
+	s.waitFor(t, b, service, "the registered instance is not updated", func(instances []discovery.Instance) bool {
+		return len(instances) == 1 && instances[0].Weight() == 30
+	})
+
+	require.Nil(t, b.Registry.Deregister(updated))
+	s.waitForAddrs(t, b, service)
+}
+
+func (s *suite) testDeregisterUnknown(t *testing.T, b Backend, service string) {
+	info := newInfo(service, basePort)
+	require.Nil(t, b.Registry.Register(info))
+	t.Cleanup(func() { _ = b.Registry.Deregister(info) })
+	s.waitForAddrs(t, b, service, info.Addr.String())
+
+	assert.Nil(t, b.Registry.Deregister(newInfo(service, basePort+1)), "deregistering an unknown instance should succeed")
+	assert.Nil(t, b.Registry.Deregister(newInfo(service+".unknown", basePort)), "deregistering an unknown service should succeed")
+	s.waitForAddrs(t, b, service, info.Addr.String())
+}
+
+func (s *suite) testConcurrentRegistration(t *testing.T, b Backend, service string) {
+	const n = 10
+	infos := make([]*registry.Info, n)
+	addrs := make([]string, n)
+	for i := range infos {
+		infos[i] = newInfo(service, basePort+i)
+		addrs[i] = infos[i].Addr.String()
+	}
+
+	run := func(fn func(info *registry.Info) error) {
+		var wg sync.WaitGroup
+		for _, info := range infos {
+			wg.Add(1)
+			go func(info *registry.Info) {
+				defer wg.Done()
+				assert.Nil(t, fn(info))
+			}(info)
+		}
+		wg.Wait()
+	}
+	run(b.Registry.Register)
+	s.waitForAddrs(t, b, service, addrs...)
+	run(b.Registry.Deregister)
+	s.waitForAddrs(t, b, service)
+}
+
+func (s *suite) testInvalidInfo(t *testing.T, b Backend, service string) {
+	assert.NotNil(t, b.Registry.Register(nil), "registering a nil info should fail")
+	assert.NotNil(t, b.Registry.Register(&registry.Info{
+		Addr:   utils.NewNetAddr("tcp", "127.0.0.1:18000"),
+		Weight: registry.DefaultWeight,
+	}), "registering an info without service name should fail")
+	assert.NotNil(t, b.Registry.Register(&registry.Info{
+		ServiceName: service,
+		Weight:      registry.DefaultWeight,
+	}), "registering an info without address should fail")
+}
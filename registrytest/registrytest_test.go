@@ -0,0 +1,113 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registrytest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/app/server/registry"
+)
+
+// mapBackend is a minimal backend which conforms to the tests.
+type mapBackend struct {
+	mu        sync.Mutex
+	instances map[string]map[string]*registry.Info
+	// rejectDuplicate makes the backend fail to register an instance again
+	rejectDuplicate bool
+}
+
+func newMapBackend() *mapBackend {
+	return &mapBackend{instances: make(map[string]map[string]*registry.Info)}
+}
+
+func (m *mapBackend) address(info *registry.Info) (string, error) {
+	if info == nil || info.ServiceName == "" || info.Addr == nil {
+		return "", errors.New("invalid registry.Info")
+	}
+	host, port, err := net.SplitHostPort(info.Addr.String())
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func (m *mapBackend) Register(info *registry.Info) error {
+	addr, err := m.address(info)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.instances[info.ServiceName] == nil {
+		m.instances[info.ServiceName] = make(map[string]*registry.Info)
+	}
+	if _, ok := m.instances[info.ServiceName][addr]; ok && m.rejectDuplicate {
+		return errors.New("instance already registered")
+	}
+	m.instances[info.ServiceName][addr] = info
+	return nil
+}
+
+func (m *mapBackend) Deregister(info *registry.Info) error {
+	addr, err := m.address(info)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.instances[info.ServiceName], addr)
+	return nil
+}
+
+func (m *mapBackend) Target(_ context.Context, target *discovery.TargetInfo) string {
+	return target.Host
+}
+
+func (m *mapBackend) Resolve(_ context.Context, desc string) (discovery.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var instances []discovery.Instance
+	for addr, info := range m.instances[desc] {
+		instances = append(instances, discovery.NewInstance(info.Addr.Network(), addr, info.Weight, info.Tags))
+	}
+	return discovery.Result{CacheKey: desc, Instances: instances}, nil
+}
+
+func (m *mapBackend) Name() string {
+	return "map"
+}
+
+func TestRun(t *testing.T) {
+	Run(t, func(t *testing.T) Backend {
+		b := newMapBackend()
+		return Backend{Registry: b, Resolver: b}
+	})
+}
+
+func TestRunWithSkip(t *testing.T) {
+	Run(t, func(t *testing.T) Backend {
+		b := newMapBackend()
+		b.rejectDuplicate = true
+		return Backend{Registry: b, Resolver: b}
+	}, WithSkip(CaseDuplicateRegistration, CaseConcurrentRegistration), WithServiceNamePrefix("hertz.skip"))
+}
@@ -24,9 +24,9 @@ import (
 
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
-	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/go-chassis/cari/discovery"
 	"github.com/go-chassis/sc-client"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/thoas/go-funk"
 )
 
@@ -42,6 +42,7 @@ type registryOptions struct {
 	versionRule       string
 	hostName          string
 	heartbeatInterval int32
+	addressOpts       []address.Option
 }
 
 // RegistryOption is ServiceComb option.
@@ -75,6 +76,13 @@ func WithRegistryHeartbeatInterval(second int32) RegistryOption {
 	}
 }
 
+// WithRegistryAddressOptions with the options deciding the address to register, see the address package
+func WithRegistryAddressOptions(opts ...address.Option) RegistryOption {
+	return func(o *registryOptions) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
+
 type serviceCombRegistry struct {
 	cli         *sc.Client
 	opts        registryOptions
@@ -118,7 +126,7 @@ func (scr *serviceCombRegistry) Register(info *registry.Info) error {
 	if err != nil {
 		return err
 	}
-	addr, err := scr.parseAddr(info.Addr.String())
+	addr, err := scr.parseAddr(info.Addr)
 	if err != nil {
 		return err
 	}
@@ -181,7 +189,7 @@ func (scr *serviceCombRegistry) Deregister(info *registry.Info) error {
 		return err
 	}
 
-	addr, err := scr.parseAddr(info.Addr.String())
+	addr, err := scr.parseAddr(info.Addr)
 	if err != nil {
 		return err
 	}
@@ -199,15 +207,9 @@ func (scr *serviceCombRegistry) Deregister(info *registry.Info) error {
 		return fmt.Errorf("instance{%s} has not registered", instanceKey)
 	}
 
-	instanceId := ""
-	instances, err := scr.cli.FindMicroServiceInstances("", scr.opts.appId, info.ServiceName, scr.opts.versionRule, sc.WithoutRevision())
+	instanceId, err := scr.findInstanceId(info.ServiceName, addr)
 	if err != nil {
-		return fmt.Errorf("get instances error: %w", err)
-	}
-	for _, instance := range instances {
-		if funk.ContainsString(instance.Endpoints, addr) {
-			instanceId = instance.InstanceId
-		}
+		return err
 	}
 	if instanceId != "" {
 		// unregister is too slow to take effect, update status to down first.
@@ -228,6 +230,51 @@ func (scr *serviceCombRegistry) Deregister(info *registry.Info) error {
 	return nil
 }
 
+// Drain updates the status of an instance to down, so that it is no longer resolved while it is still registered.
+func (scr *serviceCombRegistry) Drain(info *registry.Info) error {
+	err := scr.validRegistryInfo(info)
+	if err != nil {
+		return err
+	}
+
+	addr, err := scr.parseAddr(info.Addr)
+	if err != nil {
+		return err
+	}
+
+	serviceId, err := scr.cli.GetMicroServiceID(scr.opts.appId, info.ServiceName, scr.opts.versionRule, "")
+	if err != nil {
+		return fmt.Errorf("get service-id error: %w", err)
+	}
+
+	instanceId, err := scr.findInstanceId(info.ServiceName, addr)
+	if err != nil {
+		return err
+	}
+	if instanceId == "" {
+		return fmt.Errorf("instance{%s:%s} has not registered", info.ServiceName, addr)
+	}
+	_, err = scr.cli.UpdateMicroServiceInstanceStatus(serviceId, instanceId, sc.MSIinstanceDown)
+	if err != nil {
+		return fmt.Errorf("down service error: %w", err)
+	}
+	return nil
+}
+
+func (scr *serviceCombRegistry) findInstanceId(serviceName, addr string) (string, error) {
+	instanceId := ""
+	instances, err := scr.cli.FindMicroServiceInstances("", scr.opts.appId, serviceName, scr.opts.versionRule, sc.WithoutRevision())
+	if err != nil {
+		return "", fmt.Errorf("get instances error: %w", err)
+	}
+	for _, instance := range instances {
+		if funk.ContainsString(instance.Endpoints, addr) {
+			instanceId = instance.InstanceId
+		}
+	}
+	return instanceId, nil
+}
+
 func (scr *serviceCombRegistry) heartBeat(ctx context.Context, serviceId, instanceId string) {
 	ticker := time.NewTicker(time.Second * time.Duration(scr.opts.heartbeatInterval))
 	for {
@@ -259,17 +306,10 @@ func (scr *serviceCombRegistry) validRegistryInfo(info *registry.Info) error {
 	return nil
 }
 
-func (scr *serviceCombRegistry) parseAddr(s string) (string, error) {
-	host, port, err := net.SplitHostPort(s)
+func (scr *serviceCombRegistry) parseAddr(addr net.Addr) (string, error) {
+	s, err := address.Advertise(addr, scr.opts.addressOpts...)
 	if err != nil {
 		return "", fmt.Errorf("parse addr error: %w", err)
 	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
-		if host == utils.UNKNOWN_IP_ADDR {
-			return "", errors.New("get local ip error")
-		}
-	}
-
-	return net.JoinHostPort(host, port), nil
+	return s, nil
 }
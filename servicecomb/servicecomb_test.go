@@ -29,6 +29,7 @@ import (
 	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -113,7 +114,8 @@ func TestServiceCombDiscovery(t *testing.T) {
 
 		host, port, err := net.SplitHostPort(instance.Address().String())
 		assert.Nil(t, err)
-		local := utils.LocalIP()
+		local, _, err := address.AdvertiseHostPort(info.Addr)
+		assert.Nil(t, err)
 
 		if host != local {
 			t.Errorf("instance host is mismatch, expect: %s, in fact: %s", local, host)
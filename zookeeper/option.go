@@ -0,0 +1,37 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import "github.com/hertz-contrib/registry/address"
+
+type options struct {
+	addressOpts []address.Option
+}
+
+// Option is the option of zookeeper registry.
+type Option func(o *options)
+
+// WithAddressOptions sets how the registry decides the address to register, see the address package.
+func WithAddressOptions(opts ...address.Option) Option {
+	return func(o *options) {
+		o.addressOpts = append(o.addressOpts, opts...)
+	}
+}
+
+func (o *options) apply(opts ...Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
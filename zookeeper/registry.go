@@ -17,14 +17,15 @@ package zookeeper
 import (
 	"errors"
 	"fmt"
-	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/server/registry"
-	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/go-zookeeper/zk"
+	"github.com/hertz-contrib/registry/address"
 )
 
 const (
@@ -41,13 +42,19 @@ type zookeeperRegistry struct {
 	conn           *zk.Conn
 	authOpen       bool
 	user, password string
+	opts           options
+
+	mu sync.Mutex
+	// nodes holds the content of every registered ephemeral node, keyed by path,
+	// so that they can be created again after the session expires
+	nodes map[string][]byte
 }
 
 func (z *zookeeperRegistry) Register(info *registry.Info) error {
 	if err := z.validRegistryInfo(info); err != nil {
 		return fmt.Errorf("valid parse registry info error: %w", err)
 	}
-	path, err := buildPath(info)
+	path, err := buildPath(info, z.opts.addressOpts...)
 	if err != nil {
 		return err
 	}
@@ -55,7 +62,13 @@ func (z *zookeeperRegistry) Register(info *registry.Info) error {
 	if err != nil {
 		return err
 	}
-	return z.createNode(path, content, true)
+	if err := z.createNode(path, content, true); err != nil {
+		return err
+	}
+	z.mu.Lock()
+	z.nodes[path] = content
+	z.mu.Unlock()
+	return nil
 }
 
 func (z *zookeeperRegistry) Deregister(info *registry.Info) error {
@@ -63,26 +76,32 @@ func (z *zookeeperRegistry) Deregister(info *registry.Info) error {
 		return fmt.Errorf("valid parse registry info error: %w", err)
 	}
 
-	path, err := buildPath(info)
+	path, err := buildPath(info, z.opts.addressOpts...)
 	if err != nil {
 		return err
 	}
+	z.mu.Lock()
+	delete(z.nodes, path)
+	z.mu.Unlock()
 	return z.deleteNode(path)
 }
 
-func NewZookeeperRegistry(servers []string, sessionTimeout time.Duration) (registry.Registry, error) {
-	conn, _, err := zk.Connect(servers, sessionTimeout)
+func NewZookeeperRegistry(servers []string, sessionTimeout time.Duration, opts ...Option) (registry.Registry, error) {
+	conn, events, err := zk.Connect(servers, sessionTimeout)
 	if err != nil {
 		return nil, err
 	}
-	return &zookeeperRegistry{conn: conn}, nil
+	z := &zookeeperRegistry{conn: conn, nodes: make(map[string][]byte)}
+	z.opts.apply(opts...)
+	go z.watchSession(events)
+	return z, nil
 }
 
-func NewZookeeperRegistryWithAuth(servers []string, sessionTimeout time.Duration, user, password string) (registry.Registry, error) {
+func NewZookeeperRegistryWithAuth(servers []string, sessionTimeout time.Duration, user, password string, opts ...Option) (registry.Registry, error) {
 	if user == "" || password == "" {
 		return nil, fmt.Errorf("user or password can't be empty")
 	}
-	conn, _, err := zk.Connect(servers, sessionTimeout)
+	conn, events, err := zk.Connect(servers, sessionTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +110,99 @@ func NewZookeeperRegistryWithAuth(servers []string, sessionTimeout time.Duration
 	if err != nil {
 		return nil, err
 	}
-	return &zookeeperRegistry{conn: conn, authOpen: true, user: user, password: password}, nil
+	z := &zookeeperRegistry{conn: conn, authOpen: true, user: user, password: password, nodes: make(map[string][]byte)}
+	z.opts.apply(opts...)
+	go z.watchSession(events)
+	return z, nil
+}
+
+// watchSession re-creates the registered ephemeral nodes once a new session
+// is established after the previous one has expired. The nodes are re-created by
+// another goroutine, so that the events are still read while they are re-created.
+func (z *zookeeperRegistry) watchSession(events <-chan zk.Event) {
+	// restore holds at most one pending restoration, which covers every session established meanwhile
+	restore := make(chan struct{}, 1)
+	defer close(restore)
+	go func() {
+		for range restore {
+			z.restoreNodes()
+		}
+	}()
+
+	expired := false
+	for ev := range events {
+		if ev.Type != zk.EventSession {
+			continue
+		}
+		switch ev.State {
+		case zk.StateExpired:
+			hlog.Warnf("HERTZ: zookeeper session expired, registered nodes will be re-created")
+			expired = true
+		case zk.StateHasSession:
+			if expired {
+				expired = false
+				select {
+				case restore <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+var errNodeConflict = errors.New("node is owned by another session")
+
+// restoreNodes creates every registered node which is not owned by the current session.
+func (z *zookeeperRegistry) restoreNodes() {
+	z.mu.Lock()
+	paths := make([]string, 0, len(z.nodes))
+	for path := range z.nodes {
+		paths = append(paths, path)
+	}
+	z.mu.Unlock()
+
+	for _, path := range paths {
+		for {
+			z.mu.Lock()
+			content, ok := z.nodes[path]
+			z.mu.Unlock()
+			if !ok {
+				// deregistered in the meantime
+				break
+			}
+			err := z.restoreNode(path, content)
+			if err == nil {
+				hlog.Infof("HERTZ: re-created zookeeper node %s", path)
+				break
+			}
+			if errors.Is(err, errNodeConflict) {
+				hlog.Errorf("HERTZ: zookeeper node %s is registered by another process advertising the same address, skip it", path)
+				break
+			}
+			if isClosing(err) {
+				return
+			}
+			hlog.Warnf("HERTZ: re-create zookeeper node %s failed with err: %v", path, err)
+			time.Sleep(rewatchDelay)
+		}
+	}
+}
+
+func (z *zookeeperRegistry) restoreNode(path string, content []byte) error {
+	err := z.createNode(path, content, true)
+	if !errors.Is(err, zk.ErrNodeExists) {
+		return err
+	}
+	_, stat, err := z.conn.Get(path)
+	if err != nil {
+		return err
+	}
+	if stat.EphemeralOwner == z.conn.SessionID() {
+		return nil
+	}
+	// the ephemeral nodes of the expired session are removed already,
+	// so that the node belongs to another live session, which must not be deleted
+	return errNodeConflict
 }
 
 func (z *zookeeperRegistry) validRegistryInfo(info *registry.Info) error {
@@ -108,22 +219,16 @@ func (z *zookeeperRegistry) validRegistryInfo(info *registry.Info) error {
 }
 
 // buildPath path format as follows: {serviceName}/{ip}:{port}
-func buildPath(info *registry.Info) (string, error) {
+func buildPath(info *registry.Info, opts ...address.Option) (string, error) {
 	var path string
 	if !strings.HasPrefix(info.ServiceName, Separator) {
 		path = Separator + info.ServiceName
 	}
-	host, port, err := net.SplitHostPort(info.Addr.String())
+	addr, err := address.Advertise(info.Addr, opts...)
 	if err != nil {
-		return "", fmt.Errorf("parse registry info addr error")
-	}
-	if port == "" {
-		return "", fmt.Errorf("registry info addr missing port")
-	}
-	if host == "" || host == "::" {
-		host = utils.LocalIP()
+		return "", fmt.Errorf("parse registry info addr error: %w", err)
 	}
-	path = path + Separator + net.JoinHostPort(host, port)
+	path = path + Separator + addr
 
 	return path, nil
 }
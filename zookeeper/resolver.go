@@ -16,17 +16,38 @@ package zookeeper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/hertz/pkg/app/client/discovery"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/go-zookeeper/zk"
 )
 
+const rewatchDelay = time.Second
+
 type zookeeperResolver struct {
 	conn *zk.Conn
+
+	mu       sync.Mutex
+	watchers map[string]*serviceWatcher
+}
+
+// serviceWatcher keeps the instances of one service path in memory,
+// maintained by a children watch on the path and a data watch on every endpoint node.
+type serviceWatcher struct {
+	desc string
+	path string
+
+	mu        sync.RWMutex
+	instances map[string]discovery.Instance
+	// nodes holds the stop channel of the data watch of every endpoint
+	nodes map[string]chan struct{}
 }
 
 // NewZookeeperResolver create a zookeeper based resolver
@@ -35,7 +56,7 @@ func NewZookeeperResolver(servers []string, sessionTimeout time.Duration) (disco
 	if err != nil {
 		return nil, err
 	}
-	return &zookeeperResolver{conn: conn}, nil
+	return newZookeeperResolver(conn), nil
 }
 
 // NewZookeeperResolver create a zookeeper based resolver with auth
@@ -49,64 +70,272 @@ func NewZookeeperResolverWithAuth(servers []string, sessionTimeout time.Duration
 	if err != nil {
 		return nil, err
 	}
-	return &zookeeperResolver{conn: conn}, nil
+	return newZookeeperResolver(conn), nil
+}
+
+func newZookeeperResolver(conn *zk.Conn) *zookeeperResolver {
+	return &zookeeperResolver{
+		conn:     conn,
+		watchers: make(map[string]*serviceWatcher),
+	}
 }
 
 func (z *zookeeperResolver) Target(_ context.Context, target *discovery.TargetInfo) string {
 	return target.Host
 }
 
+// Resolve returns the instances of desc from memory.
+// The first call for a desc loads the instances from zookeeper and sets the watches.
 func (z *zookeeperResolver) Resolve(_ context.Context, desc string) (discovery.Result, error) {
 	path := desc
 	if !strings.HasPrefix(path, Separator) {
 		path = Separator + path
 	}
-	eps, err := z.getEndPoints(path)
+	w, err := z.getWatcher(desc, path)
 	if err != nil {
 		return discovery.Result{}, err
 	}
+	return w.result(), nil
+}
 
-	instances, err := z.getInstances(eps, path)
+func (z *zookeeperResolver) Name() string {
+	return "zookeeper"
+}
+
+// getWatcher returns the watcher of desc, loading the instances and setting the watches if needed.
+func (z *zookeeperResolver) getWatcher(desc, path string) (*serviceWatcher, error) {
+	z.mu.Lock()
+	w, ok := z.watchers[desc]
+	z.mu.Unlock()
+	if ok {
+		return w, nil
+	}
+
+	w, ch, dchs, err := z.load(desc, path)
 	if err != nil {
-		return discovery.Result{}, err
+		return nil, err
 	}
-	res := discovery.Result{
-		CacheKey:  desc,
-		Instances: instances,
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	// another goroutine may have started the watcher while we were loading,
+	// the watches set by this load fire once into their buffered channels and are dropped
+	if exist, ok := z.watchers[desc]; ok {
+		return exist, nil
 	}
-	return res, nil
+	z.watchers[desc] = w
+	w.mu.RLock()
+	for ep, stop := range w.nodes {
+		go z.watchNode(w, ep, stop, dchs[ep])
+	}
+	w.mu.RUnlock()
+	go z.watchChildren(w, ch)
+	return w, nil
 }
 
-func (z *zookeeperResolver) getEndPoints(path string) ([]string, error) {
-	child, _, err := z.conn.Children(path)
-	return child, err
+// load reads the instances of path and sets the children watch of path and the data watch of every endpoint,
+// which are returned to be handed to the goroutines of the watcher.
+func (z *zookeeperResolver) load(desc, path string) (*serviceWatcher, <-chan zk.Event, map[string]<-chan zk.Event, error) {
+	eps, _, ch, err := z.conn.ChildrenW(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	instances := make(map[string]discovery.Instance, len(eps))
+	dchs := make(map[string]<-chan zk.Event, len(eps))
+	for _, ep := range eps {
+		data, _, dch, err := z.conn.GetW(path + Separator + ep)
+		if errors.Is(err, zk.ErrNoNode) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("detail endpoint [%s] info error, cause %w", ep, err)
+		}
+		ins, err := parseEndPoint(ep, data)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("detail endpoint [%s] info error, cause %w", ep, err)
+		}
+		instances[ep] = ins
+		dchs[ep] = dch
+	}
+
+	w := &serviceWatcher{
+		desc: desc,
+		path: path,
+	}
+	w.mu.Lock()
+	w.instances = instances
+	w.nodes = make(map[string]chan struct{}, len(instances))
+	for ep := range instances {
+		w.nodes[ep] = make(chan struct{})
+	}
+	w.mu.Unlock()
+	return w, ch, dchs, nil
 }
 
-func (z *zookeeperResolver) getInstances(eps []string, path string) ([]discovery.Instance, error) {
-	instances := make([]discovery.Instance, 0, len(eps))
+// watchChildren re-arms the children watch of w every time it fires,
+// and starts or stops the data watches of the endpoints accordingly.
+func (z *zookeeperResolver) watchChildren(w *serviceWatcher, ch <-chan zk.Event) {
+	for {
+		ev := <-ch
+		if isClosing(ev.Err) {
+			return
+		}
+		if ev.Type == zk.EventNotWatching {
+			// the session has expired, wait for a new one
+			hlog.Warnf("HERTZ: children watch of %s is lost, cause %v", w.path, ev.Err)
+			time.Sleep(rewatchDelay)
+		}
+
+		for {
+			eps, _, newCh, err := z.conn.ChildrenW(w.path)
+			if err == nil {
+				w.syncChildren(z, eps)
+				ch = newCh
+				break
+			}
+			if isClosing(err) {
+				return
+			}
+			if errors.Is(err, zk.ErrNoNode) {
+				// the service path is gone, wait for it to be created again
+				w.syncChildren(z, nil)
+				var exists bool
+				exists, _, newCh, err = z.conn.ExistsW(w.path)
+				if err == nil {
+					if !exists {
+						ch = newCh
+						break
+					}
+					continue
+				}
+				if isClosing(err) {
+					return
+				}
+			}
+			hlog.Warnf("HERTZ: watch children of %s failed with err: %v", w.path, err)
+			time.Sleep(rewatchDelay)
+		}
+	}
+}
+
+// watchNode keeps the instance of ep up to date until its node is deleted or stop is closed.
+// ch is the pending data watch of the node, or nil if the watch is not set yet.
+func (z *zookeeperResolver) watchNode(w *serviceWatcher, ep string, stop chan struct{}, ch <-chan zk.Event) {
+	nodePath := w.path + Separator + ep
+	for {
+		if ch == nil {
+			data, _, newCh, err := z.conn.GetW(nodePath)
+			if errors.Is(err, zk.ErrNoNode) {
+				w.removeNode(ep, stop)
+				return
+			}
+			if isClosing(err) {
+				return
+			}
+			if err != nil {
+				hlog.Warnf("HERTZ: watch endpoint %s failed with err: %v", nodePath, err)
+				select {
+				case <-stop:
+					return
+				case <-time.After(rewatchDelay):
+				}
+				continue
+			}
+			ins, err := parseEndPoint(ep, data)
+			if err != nil {
+				hlog.Warnf("HERTZ: detail endpoint [%s] info error, cause %v", ep, err)
+			} else {
+				w.setInstance(ep, ins)
+			}
+			ch = newCh
+		}
+
+		select {
+		case <-stop:
+			return
+		case ev := <-ch:
+			ch = nil
+			if isClosing(ev.Err) {
+				return
+			}
+			if ev.Type == zk.EventNodeDeleted {
+				w.removeNode(ep, stop)
+				return
+			}
+			if ev.Type == zk.EventNotWatching {
+				time.Sleep(rewatchDelay)
+			}
+		}
+	}
+}
+
+// syncChildren starts the data watches of the new endpoints and stops the ones of the removed endpoints.
+func (w *serviceWatcher) syncChildren(z *zookeeperResolver, eps []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	current := make(map[string]struct{}, len(eps))
 	for _, ep := range eps {
-		ins, err := z.detailEndPoints(path, ep)
-		if err != nil {
-			return []discovery.Instance{}, fmt.Errorf("detail endpoint [%s] info error, cause %w", ep, err)
+		current[ep] = struct{}{}
+		if _, ok := w.nodes[ep]; !ok {
+			stop := make(chan struct{})
+			w.nodes[ep] = stop
+			go z.watchNode(w, ep, stop, nil)
+		}
+	}
+	for ep, stop := range w.nodes {
+		if _, ok := current[ep]; !ok {
+			close(stop)
+			delete(w.nodes, ep)
+			delete(w.instances, ep)
 		}
-		instances = append(instances, ins)
 	}
-	return instances, nil
 }
 
-func (z *zookeeperResolver) detailEndPoints(path, ep string) (discovery.Instance, error) {
-	data, _, err := z.conn.Get(path + Separator + ep)
-	if err != nil {
-		return nil, err
+func (w *serviceWatcher) setInstance(ep string, ins discovery.Instance) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.nodes[ep]; ok {
+		w.instances[ep] = ins
+	}
+}
+
+// removeNode removes ep if its data watch is still the one identified by stop.
+func (w *serviceWatcher) removeNode(ep string, stop chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nodes[ep] == stop {
+		delete(w.nodes, ep)
+		delete(w.instances, ep)
+	}
+}
+
+func (w *serviceWatcher) result() discovery.Result {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	eps := make([]string, 0, len(w.instances))
+	for ep := range w.instances {
+		eps = append(eps, ep)
+	}
+	sort.Strings(eps)
+	instances := make([]discovery.Instance, 0, len(eps))
+	for _, ep := range eps {
+		instances = append(instances, w.instances[ep])
+	}
+	return discovery.Result{
+		CacheKey:  w.desc,
+		Instances: instances,
 	}
+}
+
+func parseEndPoint(ep string, data []byte) (discovery.Instance, error) {
 	en := new(RegistryEntity)
-	err = sonic.Unmarshal(data, en)
+	err := sonic.Unmarshal(data, en)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal data [%s] error, cause %w", data, err)
 	}
 	return discovery.NewInstance("tcp", ep, en.Weight, en.Tags), nil
 }
 
-func (z *zookeeperResolver) Name() string {
-	return "zookeeper"
+func isClosing(err error) bool {
+	return errors.Is(err, zk.ErrClosing) || errors.Is(err, zk.ErrConnectionClosed)
 }
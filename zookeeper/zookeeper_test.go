@@ -30,6 +30,8 @@ import (
 	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/go-zookeeper/zk"
+	"github.com/hertz-contrib/registry/address"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -105,7 +107,8 @@ func TestZookeeperDiscovery(t *testing.T) {
 		instance := result.Instances[0]
 		host, port, err := net.SplitHostPort(instance.Address().String())
 		assert.Nil(t, err)
-		local := utils.LocalIP()
+		local, _, err := address.AdvertiseHostPort(info.Addr)
+		assert.Nil(t, err)
 		if host != local {
 			t.Errorf("instance host is mismatch, expect: %s, in fact: %s", local, host)
 		}
@@ -126,10 +129,11 @@ func TestZookeeperDiscovery(t *testing.T) {
 	err = r.Deregister(info)
 	assert.Nil(t, err)
 
-	// resolve again
-	result, err = res.Resolve(context.Background(), target)
-	assert.Nil(t, err)
-	assert.Empty(t, result.Instances)
+	// resolve again, the instance is removed once the watch fires
+	assert.Eventually(t, func() bool {
+		result, err = res.Resolve(context.Background(), target)
+		return err == nil && len(result.Instances) == 0
+	}, 3*time.Second, 100*time.Millisecond)
 	assert.Equal(t, "product", result.CacheKey)
 }
 
@@ -158,7 +162,8 @@ func TestZookeeperDiscoveryWithAuth(t *testing.T) {
 		instance := result.Instances[0]
 		host, port, err := net.SplitHostPort(instance.Address().String())
 		assert.Nil(t, err)
-		local := utils.LocalIP()
+		local, _, err := address.AdvertiseHostPort(info.Addr)
+		assert.Nil(t, err)
 		if host != local {
 			t.Errorf("instance host is mismatch, expect: %s, in fact: %s", local, host)
 		}
@@ -179,9 +184,78 @@ func TestZookeeperDiscoveryWithAuth(t *testing.T) {
 	err = r.Deregister(info)
 	assert.Nil(t, err)
 
-	// resolve again
-	result, err = res.Resolve(context.Background(), target)
-	assert.Nil(t, err)
-	assert.Empty(t, result.Instances)
+	// resolve again, the instance is removed once the watch fires
+	assert.Eventually(t, func() bool {
+		result, err = res.Resolve(context.Background(), target)
+		return err == nil && len(result.Instances) == 0
+	}, 3*time.Second, 100*time.Millisecond)
 	assert.Equal(t, "product", result.CacheKey)
 }
+
+// TestZookeeperResolverWatch Test the resolver following the endpoint nodes of a service by watches.
+func TestZookeeperResolverWatch(t *testing.T) {
+	r, err := NewZookeeperRegistry([]string{"127.0.0.1:2181"}, 40*time.Second)
+	assert.Nil(t, err)
+	res, err := NewZookeeperResolver([]string{"127.0.0.1:2181"}, 40*time.Second)
+	assert.Nil(t, err)
+
+	first := &registry.Info{ServiceName: "watch.product", Weight: 10, Addr: utils.NewNetAddr("tcp", "127.0.0.1:9001")}
+	second := &registry.Info{ServiceName: "watch.product", Weight: 20, Addr: utils.NewNetAddr("tcp", "127.0.0.1:9002")}
+	assert.Nil(t, r.Register(first))
+
+	target := res.Target(context.Background(), &discovery.TargetInfo{Host: "watch.product"})
+	result, err := res.Resolve(context.Background(), target)
+	assert.Nil(t, err)
+	assert.Len(t, result.Instances, 1)
+
+	// a new endpoint node is picked up by the children watch
+	assert.Nil(t, r.Register(second))
+	assert.Eventually(t, func() bool {
+		result, err = res.Resolve(context.Background(), target)
+		return err == nil && len(result.Instances) == 2
+	}, 3*time.Second, 100*time.Millisecond)
+	assert.Equal(t, "127.0.0.1:9001", result.Instances[0].Address().String())
+	assert.Equal(t, 20, result.Instances[1].Weight())
+
+	// a removed endpoint node is dropped
+	assert.Nil(t, r.Deregister(first))
+	assert.Eventually(t, func() bool {
+		result, err = res.Resolve(context.Background(), target)
+		return err == nil && len(result.Instances) == 1
+	}, 3*time.Second, 100*time.Millisecond)
+	assert.Equal(t, "127.0.0.1:9002", result.Instances[0].Address().String())
+
+	assert.Nil(t, r.Deregister(second))
+	assert.Eventually(t, func() bool {
+		result, err = res.Resolve(context.Background(), target)
+		return err == nil && len(result.Instances) == 0
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+// TestZookeeperRestoreNodes Test re-creating the registered nodes after they are removed with an expired session.
+func TestZookeeperRestoreNodes(t *testing.T) {
+	r, err := NewZookeeperRegistryWithAuth([]string{"127.0.0.1:2181"}, 40*time.Second, "horizon", "horizon")
+	assert.Nil(t, err)
+	zr := r.(*zookeeperRegistry)
+	info := &registry.Info{ServiceName: "restore.product", Weight: 10, Addr: utils.NewNetAddr("tcp", "127.0.0.1:9001")}
+	assert.Nil(t, r.Register(info))
+	path, err := buildPath(info)
+	assert.Nil(t, err)
+
+	// remove the node as the server does when the session expires
+	assert.Nil(t, zr.deleteNode(path))
+	zr.restoreNodes()
+	_, stat, err := zr.conn.Get(path)
+	assert.Nil(t, err)
+	assert.Equal(t, zr.conn.SessionID(), stat.EphemeralOwner)
+	acl, _, err := zr.conn.GetACL(path)
+	assert.Nil(t, err)
+	assert.Equal(t, zk.DigestACL(zk.PermAll, "horizon", "horizon"), acl)
+
+	// deregistered nodes are not re-created
+	assert.Nil(t, r.Deregister(info))
+	zr.restoreNodes()
+	exists, _, err := zr.conn.Exists(path)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}